@@ -0,0 +1,157 @@
+package tell
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How long the buttons of a notification keep working after it was sent.
+const actionLifetime = 30 * 24 * time.Hour
+
+// Button is a notification button, which runs a command on the sending machine when pressed.
+// Commands are run by 'tell -d', only if the button was pressed in one of the chats it was sent to.
+type Button struct {
+	Label   string
+	Command string
+	token   string // The callback data identifying the button's action, see registerActions
+}
+
+// pendingAction is a command that can be run by pressing a notification button.
+// Only the command stored here can ever be run, whoever presses the button can't change it.
+type pendingAction struct {
+	Command string    `json:"command"`
+	ChatIDs []int64   `json:"chat_ids"` // The chats the button was sent to, the only ones allowed to press it
+	Created time.Time `json:"created"`
+}
+
+// DefaultActionsPath returns where the commands of sent buttons are stored, ~/.tell_actions.json.
+func DefaultActionsPath() (string, error) {
+	dirname, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	return dirname + "/.tell_actions.json", nil
+}
+
+// loadActions reads the pending actions, keyed by their tokens.
+// A missing file is not an error, there are just no actions yet.
+func loadActions(path string) (map[string]pendingAction, error) {
+	actions := make(map[string]pendingAction)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return actions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read actions file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return nil, fmt.Errorf("failed to decode actions file: %w", err)
+	}
+	return actions, nil
+}
+
+// saveActions writes the pending actions, replacing the file atomically so that a running daemon never sees half of it.
+func saveActions(path string, actions map[string]pendingAction) error {
+	data, err := json.Marshal(actions)
+	if err != nil {
+		return fmt.Errorf("failed to encode actions: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tell_actions_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create actions file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write actions file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace actions file: %w", err)
+	}
+	return nil
+}
+
+// registerActions gives each button a random token and stores its command as a pending action.
+// Actions that have expired are removed at the same time.
+func registerActions(path string, buttons []Button, chatIDs []int64) error {
+	// Other tell processes may be adding their own actions, which mustn't be lost when the file is replaced.
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock actions file: %w", err)
+	}
+	defer unlock()
+
+	actions, err := loadActions(path)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for token, action := range actions {
+		if now.Sub(action.Created) > actionLifetime {
+			delete(actions, token)
+		}
+	}
+
+	for i := range buttons {
+		token, err := newActionToken()
+		if err != nil {
+			return err
+		}
+
+		buttons[i].token = token
+		actions[token] = pendingAction{
+			Command: buttons[i].Command,
+			ChatIDs: chatIDs,
+			Created: now,
+		}
+	}
+
+	return saveActions(path, actions)
+}
+
+// LookupAction returns the command a button press from the given chat should run.
+// token is the callback data of the button.
+func LookupAction(path, token string, chatID int64) (string, error) {
+	actions, err := loadActions(path)
+	if err != nil {
+		return "", err
+	}
+
+	action, ok := actions[token]
+	if !ok || time.Since(action.Created) > actionLifetime {
+		return "", fmt.Errorf("unknown or expired action")
+	}
+
+	for _, id := range action.ChatIDs {
+		if id == chatID {
+			return action.Command, nil
+		}
+	}
+	return "", fmt.Errorf("action was not sent to chat %d", chatID)
+}
+
+// newActionToken returns an unguessable token that fits in Telegram's 64-byte callback data.
+func newActionToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
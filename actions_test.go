@@ -0,0 +1,65 @@
+package tell
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestActions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.json")
+	buttons := []Button{{Label: "Restart", Command: "make build"}, {Label: "Info", Command: "tell -f error.log"}}
+
+	if err := registerActions(path, buttons, []int64{1, 2}); err != nil {
+		t.Fatalf("failed to register actions: %s", err)
+	}
+
+	if buttons[0].token == "" || buttons[0].token == buttons[1].token {
+		t.Fatalf("buttons not given unique tokens: %q, %q", buttons[0].token, buttons[1].token)
+	}
+
+	if len(buttons[0].token) > 64 {
+		t.Errorf("token doesn't fit in callback data: %q", buttons[0].token)
+	}
+
+	command, err := LookupAction(path, buttons[1].token, 2)
+	if err != nil {
+		t.Fatalf("failed to look up action: %s", err)
+	}
+	if command != "tell -f error.log" {
+		t.Errorf("wrong command for action, expected 'tell -f error.log', got %q", command)
+	}
+
+	if _, err := LookupAction(path, buttons[0].token, 3); err == nil {
+		t.Errorf("action found for a chat the button wasn't sent to")
+	}
+
+	if _, err := LookupAction(path, "make build", 1); err == nil {
+		t.Errorf("action found for a made-up token")
+	}
+}
+
+func TestActionsConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.json")
+
+	// Like several tell processes sending notifications at once, none of their buttons may be lost.
+	buttons := make([][]Button, 20)
+	var wg sync.WaitGroup
+	for i := range buttons {
+		buttons[i] = []Button{{Label: "Restart", Command: "make build"}}
+		wg.Add(1)
+		go func(b []Button) {
+			defer wg.Done()
+			if err := registerActions(path, b, []int64{1}); err != nil {
+				t.Errorf("failed to register actions: %s", err)
+			}
+		}(buttons[i])
+	}
+	wg.Wait()
+
+	for i, b := range buttons {
+		if _, err := LookupAction(path, b[0].token, 1); err != nil {
+			t.Errorf("button %d was lost: %s", i, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package tell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// albumLimit is the most files Telegram accepts in a single media group.
+const albumLimit = 10
+
+// checkAlbum decides how each of several attached files is sent, and which of them the text goes with.
+func (msg *Message) checkAlbum(limits SizeLimits) error {
+	if len(msg.buttons) > 0 {
+		return fmt.Errorf("buttons can't be attached to several files")
+	}
+
+	for _, path := range msg.files {
+		part := msg.clone()
+		part.files = []string{path}
+		part.text = ""
+		if err := part.Check(limits); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		msg.parts = append(msg.parts, part)
+	}
+
+	if msg.text != "" {
+		// The text is the caption of the first file, unless that can't have one, or the text doesn't fit.
+		first := msg.parts[0]
+		first.text = msg.text
+		caption, _ := first.render()
+
+		if !hasCaption(first.typ) || utf16Len(caption) > captionLimit {
+			first.text = ""
+
+			// It's sent on its own before the files instead, split or turned into a file as usual if it's long.
+			text := msg.clone()
+			text.files = nil
+			text.encrypt, text.split, text.noUpload, text.typeSet = false, false, false, false
+			if err := text.Check(limits); err != nil {
+				return err
+			}
+			msg.parts = append([]*Message{text}, msg.parts...)
+		}
+	}
+
+	msg.typ = AlbumMessage
+	return nil
+}
+
+// hasCaption reports whether text can be sent together with a file of the given type.
+func hasCaption(typ MessageType) bool {
+	// Uploaded files are sent as links, and directories as archives, both of which can have text.
+	info, ok := typeInfo[typ]
+	return !ok || info.text != ""
+}
+
+// prepareAlbum prepares each file of an album, like prepare does for a single file.
+func (msg *Message) prepareAlbum(ctx context.Context) (cleanup func(), err error) {
+	for _, part := range msg.parts {
+		part.limits, part.retry, part.encryptKey, part.uploader = msg.limits, msg.retry, msg.encryptKey, msg.uploader
+
+		remove, err := part.prepare(ctx)
+		cleanup = chainCleanup(cleanup, remove)
+		if err != nil {
+			return cleanup, err
+		}
+	}
+	return cleanup, nil
+}
+
+// albumKind returns which files a prepared message can share a media group with, or "" if it has to be sent alone.
+// Telegram groups photos together with videos, but documents and audio files only with their own kind.
+func (msg *Message) albumKind() string {
+	if msg.manifest != nil || msg.filePath == "" {
+		return ""
+	}
+
+	switch msg.typ {
+	case PhotoMessage, VideoMessage:
+		return "media"
+	case DocumentMessage:
+		return "document"
+	case AudioMessage:
+		return "audio"
+	}
+	return ""
+}
+
+// sendAlbum sends the files of a prepared album in order, grouping neighbouring files Telegram can show together
+// into media groups of up to ten. Files that can't be grouped, like stickers or uploaded files, are sent on their own.
+func (msg *Message) sendAlbum(ctx context.Context, bot *gotgbot.Bot, chatID int64) ([]int64, error) {
+	var ids []int64
+	var group []*Message
+
+	sendGroup := func() error {
+		defer func() { group = nil }()
+
+		var sent []int64
+		var err error
+		switch len(group) {
+		case 0:
+			return nil
+		case 1:
+			sent, err = group[0].send(ctx, bot, chatID)
+		default:
+			sent, err = msg.sendMediaGroup(ctx, bot, chatID, group)
+			if sent == nil && rejectedType(err) {
+				// Some file wasn't accepted as its type, so each is sent on its own, falling back to a document if needed.
+				for _, part := range group {
+					sent, err = part.send(ctx, bot, chatID)
+					ids = append(ids, sent...)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+		ids = append(ids, sent...)
+		return err
+	}
+
+	for _, part := range msg.parts {
+		kind := part.albumKind()
+		if len(group) > 0 && (kind != group[0].albumKind() || len(group) == albumLimit) {
+			if err := sendGroup(); err != nil {
+				return ids, err
+			}
+		}
+
+		if kind != "" {
+			group = append(group, part)
+			continue
+		}
+
+		sent, err := part.send(ctx, bot, chatID)
+		ids = append(ids, sent...)
+		if err != nil {
+			return ids, err
+		}
+	}
+
+	return ids, sendGroup()
+}
+
+// sendMediaGroup sends several files as one message, and returns the IDs of the messages Telegram made of them.
+func (msg *Message) sendMediaGroup(ctx context.Context, bot *gotgbot.Bot, chatID int64, group []*Message) ([]int64, error) {
+	var media []map[string]any
+	data := make(map[string]gotgbot.NamedReader)
+
+	for i, part := range group {
+		f, err := os.Open(part.filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+
+		field := fmt.Sprintf("file%d", i)
+		data[field] = f
+		item := map[string]any{
+			"type":  typeInfo[part.typ].file,
+			"media": "attach://" + field,
+		}
+		if part.spoilerInAlbum() {
+			item["has_spoiler"] = true
+		}
+
+		if part.text != "" {
+			text, format := part.render()
+			item["caption"] = text
+			if format != PlainText {
+				item["parse_mode"] = string(format)
+			}
+		}
+		media = append(media, item)
+	}
+
+	mediaJSON, err := json.Marshal(media)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode album: %w", err)
+	}
+
+	params := map[string]string{
+		"chat_id": fmt.Sprint(chatID),
+		"media":   string(mediaJSON),
+	}
+	res, err := msg.request(ctx, bot, "sendMediaGroup", params, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send album: %w", err)
+	}
+
+	var sent []gotgbot.Message
+	if err := json.Unmarshal(res, &sent); err != nil {
+		return nil, fmt.Errorf("failed to decode sendMediaGroup response: %w", err)
+	}
+
+	ids := make([]int64, len(sent))
+	for i, m := range sent {
+		ids[i] = m.MessageId
+		group[i].recordSent(&sent[i])
+	}
+	return ids, nil
+}
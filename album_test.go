@@ -0,0 +1,109 @@
+package tell
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// albumSizes returns the number of files in each sendMediaGroup call.
+func albumSizes(t *testing.T, calls []map[string]string) []int {
+	t.Helper()
+
+	var sizes []int
+	for _, params := range calls {
+		var media []map[string]string
+		if err := json.Unmarshal([]byte(params["media"]), &media); err != nil {
+			t.Fatalf("invalid media: %s", err)
+		}
+		sizes = append(sizes, len(media))
+	}
+	return sizes
+}
+
+func TestSendAlbum(t *testing.T) {
+	client, api := newTestClient(t)
+
+	var photos []string
+	for i := 0; i < 12; i++ {
+		photo, cleanup, err := tempFile("jpg", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup()
+		photos = append(photos, photo)
+	}
+
+	msg := NewMessage("Screenshots").WithFile(photos[0]).WithFile(photos[1]).WithFile("testdata/foo")
+	results, err := client.Send(context.Background(), msg, "alice")
+	if err != nil {
+		t.Fatalf("failed to send album: %s", err)
+	}
+	if len(results[0].MessageIDs) != 3 {
+		t.Errorf("expected an ID for each file, got %v", results[0].MessageIDs)
+	}
+
+	groups := api.Calls("sendMediaGroup")
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("expected the photos to be sent as one album, got %v", groups)
+	}
+	var media []map[string]string
+	json.Unmarshal([]byte(groups[0].Params["media"]), &media)
+	if media[0]["caption"] != "Screenshots" || media[1]["caption"] != "" {
+		t.Errorf("expected the caption on the first photo only, got %v", media)
+	}
+	if docs := api.Calls("sendDocument"); len(docs) != 1 || docs[0].Params["caption"] != "" {
+		t.Errorf("expected the document to be sent on its own, got %v", docs)
+	}
+
+	msg = NewMessage("")
+	for _, photo := range photos {
+		msg.WithFile(photo)
+	}
+	if _, err := client.Send(context.Background(), msg, "alice"); err != nil {
+		t.Fatalf("failed to send album: %s", err)
+	}
+
+	var params []map[string]string
+	for _, c := range api.Calls("sendMediaGroup")[1:] {
+		params = append(params, c.Params)
+	}
+	if sizes := albumSizes(t, params); len(sizes) != 2 || sizes[0] != 10 || sizes[1] != 2 {
+		t.Errorf("expected albums of 10 and 2 photos, got %v", sizes)
+	}
+}
+
+func TestSendAlbumUngrouped(t *testing.T) {
+	client, api := newTestClient(t)
+
+	sticker, cleanup, err := tempFile("webp", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	photo, cleanupPhoto, err := tempFile("jpg", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupPhoto()
+
+	// Stickers can't have captions, so the text is sent first, and they can't be grouped, so neither is the photo.
+	msg := NewMessage("Look").WithFile(sticker).WithFile(photo)
+	if _, err := client.Send(context.Background(), msg, "alice"); err != nil {
+		t.Fatalf("failed to send files: %s", err)
+	}
+
+	var methods []string
+	for _, c := range api.Calls("") {
+		if c.Method != "getMe" {
+			methods = append(methods, c.Method)
+		}
+	}
+	if len(methods) != 3 || methods[0] != "sendMessage" || methods[1] != "sendSticker" || methods[2] != "sendPhoto" {
+		t.Errorf("expected the text, sticker and photo to be sent separately, got %v", methods)
+	}
+
+	if err := NewMessage("").WithFile(photo).WithFile(sticker).WithButton("Retry", "make").Check(SizeLimits{}); err == nil {
+		t.Errorf("buttons accepted on several files")
+	}
+}
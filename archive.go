@@ -0,0 +1,287 @@
+package tell
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveFormat is the kind of archive directories are sent as.
+type ArchiveFormat string
+
+const (
+	ArchiveZip    ArchiveFormat = "zip"
+	ArchiveTarGz  ArchiveFormat = "tar.gz"
+	ArchiveTarZst ArchiveFormat = "tar.zst"
+	ArchiveTar    ArchiveFormat = "tar"
+)
+
+// ParseArchiveFormat converts the name of an archive format, like "tar.gz", to an ArchiveFormat.
+func ParseArchiveFormat(format string) (ArchiveFormat, error) {
+	switch f := ArchiveFormat(format); f {
+	case ArchiveZip, ArchiveTarGz, ArchiveTarZst, ArchiveTar:
+		return f, nil
+	}
+	return "", fmt.Errorf("Invalid archive format: %s", format)
+}
+
+// archiveOptions are how a directory is archived before it's sent.
+type archiveOptions struct {
+	format    ArchiveFormat // Zip if empty
+	exclude   []string      // Patterns of files to leave out, matched against their names and paths relative to the directory
+	gitignore bool          // True if files ignored by git should be left out, as well as the .git directory itself
+}
+
+func (o archiveOptions) formatOrDefault() ArchiveFormat {
+	if o.format == "" {
+		return ArchiveZip
+	}
+	return o.format
+}
+
+// name returns the name of the archive of a directory.
+func (o archiveOptions) name(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	return filepath.Base(abs) + "." + string(o.formatOrDefault())
+}
+
+// createArchive archives a directory into a temporary file. It stops early if ctx is done.
+func createArchive(ctx context.Context, srcDirPath string, opts archiveOptions) (archivePath string, remove func(), err error) {
+	tmpdir, err := os.MkdirTemp("", "tell")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	remove = func() {
+		os.RemoveAll(tmpdir)
+	}
+
+	archivePath = filepath.Join(tmpdir, opts.name(srcDirPath))
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return "", remove, fmt.Errorf("failed to create archive file: %w", err)
+	}
+
+	err = writeArchive(ctx, f, srcDirPath, opts)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write archive file: %w", closeErr)
+	}
+	if err != nil {
+		return "", remove, err
+	}
+	return archivePath, remove, nil
+}
+
+// archiveWriter adds files to an archive.
+type archiveWriter interface {
+	// add adds the file at path to the archive under name, a slash-separated path ending in a slash for directories.
+	add(name, path string, info fs.FileInfo) error
+	Close() error
+}
+
+// writeArchive writes an archive of a directory to w. Symlinks are stored as symlinks, not followed,
+// and the permissions of files are kept. It stops early if ctx is done.
+func writeArchive(ctx context.Context, w io.Writer, srcDirPath string, opts archiveOptions) error {
+	var aw archiveWriter
+	switch opts.formatOrDefault() {
+	case ArchiveZip:
+		aw = &zipArchive{w: zip.NewWriter(w)}
+	case ArchiveTar:
+		aw = &tarArchive{w: tar.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		aw = &tarArchive{w: tar.NewWriter(gz), compressor: gz}
+	case ArchiveTarZst:
+		zst, err := zstd.NewWriter(w)
+		if err != nil {
+			return fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		aw = &tarArchive{w: tar.NewWriter(zst), compressor: zst}
+	default:
+		return fmt.Errorf("invalid archive format: %s", opts.format)
+	}
+
+	err := walkArchive(ctx, srcDirPath, opts, aw.add)
+	if closeErr := aw.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to finish archive: %w", closeErr)
+	}
+	return err
+}
+
+// walkArchive calls add for every file and directory under srcDirPath that isn't excluded, in lexical order.
+// Names are relative to srcDirPath, slash-separated, and end in a slash for directories.
+func walkArchive(ctx context.Context, srcDirPath string, opts archiveOptions, add func(name, path string, info fs.FileInfo) error) error {
+	srcDirPath, err := filepath.Abs(srcDirPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	// The folder itself may be a link, only links inside it are archived as links.
+	srcDirPath, err = filepath.EvalSymlinks(srcDirPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve folder: %w", err)
+	}
+
+	var ignored gitignore
+	err = filepath.Walk(srcDirPath, func(srcFilePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(srcDirPath, srcFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." {
+			if excluded(rel, opts.exclude) || (opts.gitignore && (info.Name() == ".git" || ignored.match(rel, info.IsDir()))) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if info.IsDir() && opts.gitignore {
+			if err := ignored.load(srcFilePath, rel); err != nil {
+				return err
+			}
+		}
+
+		if rel == "." {
+			return nil
+		}
+		if info.IsDir() {
+			rel += "/"
+		}
+		return add(rel, srcFilePath, info)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk directory: %w", err)
+	}
+	return nil
+}
+
+// excluded reports whether a file matches any of the patterns, either by its name or its path relative to the archived directory.
+func excluded(rel string, patterns []string) bool {
+	name := path.Base(rel)
+	for _, p := range patterns {
+		p = strings.TrimSuffix(filepath.ToSlash(p), "/")
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// zipArchive writes Deflate-compressed zip archives.
+type zipArchive struct {
+	w *zip.Writer
+}
+
+func (a *zipArchive) add(name, path string, info fs.FileInfo) error {
+	fh, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("failed to create file header: %w", err)
+	}
+	fh.Name = name
+	if !info.IsDir() {
+		fh.Method = zip.Deflate
+	}
+
+	fileWriter, err := a.w.CreateHeader(fh)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		// Zip stores symlinks as files containing their target, marked as links by their mode.
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("failed to read symlink: %w", err)
+		}
+		_, err = io.WriteString(fileWriter, target)
+		return err
+	case info.Mode().IsRegular():
+		return copyInto(fileWriter, path)
+	}
+	return nil
+}
+
+func (a *zipArchive) Close() error {
+	return a.w.Close()
+}
+
+// tarArchive writes tar archives, optionally compressed.
+type tarArchive struct {
+	w          *tar.Writer
+	compressor io.WriteCloser // Closed after the tar writer, if set
+}
+
+func (a *tarArchive) add(name, path string, info fs.FileInfo) error {
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(path); err != nil {
+			return fmt.Errorf("failed to read symlink: %w", err)
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("failed to create file header: %w", err)
+	}
+	hdr.Name = name
+
+	if err := a.w.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	if info.Mode().IsRegular() {
+		return copyInto(a.w, path)
+	}
+	return nil
+}
+
+func (a *tarArchive) Close() error {
+	err := a.w.Close()
+	if a.compressor != nil {
+		if closeErr := a.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// copyInto copies the contents of the file at path to w.
+func copyInto(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to copy %s to archive: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package tell
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveDir creates a directory to archive, with a .gitignore, an executable script and a symlink.
+func archiveDir(t *testing.T) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "project")
+	files := map[string]string{
+		".gitignore":              "*.log\n/build/\n!keep.log\n",
+		".git/HEAD":               "ref: refs/heads/main\n",
+		"main.go":                 "package main\n",
+		"debug.log":               "noise\n",
+		"keep.log":                "important\n",
+		"build/main":              "binary\n",
+		"node_modules/x/index.js": "module.exports = 1\n",
+		"docs/.gitignore":         "draft.md\n",
+		"docs/draft.md":           "wip\n",
+		"docs/index.md":           "# Docs\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("main.go", filepath.Join(dir, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// archiveEntry is a file read back from an archive.
+type archiveEntry struct {
+	mode fs.FileMode
+	link string // The target, for symlinks
+}
+
+// readArchive lists the entries of an archive in any of the formats tell writes.
+func readArchive(t *testing.T, data []byte, format ArchiveFormat) map[string]archiveEntry {
+	t.Helper()
+
+	entries := make(map[string]archiveEntry)
+	if format == ArchiveZip {
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("invalid zip archive: %s", err)
+		}
+		for _, f := range r.File {
+			entry := archiveEntry{mode: f.Mode()}
+			if f.Mode()&fs.ModeSymlink != 0 {
+				rc, _ := f.Open()
+				target, _ := io.ReadAll(rc)
+				rc.Close()
+				entry.link = string(target)
+			}
+			entries[f.Name] = entry
+		}
+		return entries
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	switch format {
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("invalid gzip stream: %s", err)
+		}
+		r = gz
+	case ArchiveTarZst:
+		zst, err := zstd.NewReader(r)
+		if err != nil {
+			t.Fatalf("invalid zstd stream: %s", err)
+		}
+		defer zst.Close()
+		r = zst
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid tar archive: %s", err)
+		}
+		entries[hdr.Name] = archiveEntry{mode: hdr.FileInfo().Mode(), link: hdr.Linkname}
+	}
+	return entries
+}
+
+func TestWriteArchive(t *testing.T) {
+	dir := archiveDir(t)
+	opts := archiveOptions{exclude: []string{"node_modules"}, gitignore: true}
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarZst} {
+		opts.format = format
+
+		var buf bytes.Buffer
+		if err := writeArchive(context.Background(), &buf, dir, opts); err != nil {
+			t.Fatalf("%s: failed to write archive: %s", format, err)
+		}
+		entries := readArchive(t, buf.Bytes(), format)
+
+		var names []string
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		want := ".gitignore docs/ docs/.gitignore docs/index.md keep.log link.go main.go run.sh"
+		if got := strings.Join(names, " "); got != want {
+			t.Errorf("%s: wrong files archived, expected %s, got %s", format, want, got)
+		}
+
+		if e := entries["link.go"]; e.mode&fs.ModeSymlink == 0 || e.link != "main.go" {
+			t.Errorf("%s: symlink not kept, got %+v", format, e)
+		}
+		if e := entries["run.sh"]; e.mode.Perm() != 0o755 {
+			t.Errorf("%s: mode not kept, expected 0755, got %o", format, e.mode.Perm())
+		}
+	}
+}
+
+func TestArchiveLinkedFolder(t *testing.T) {
+	dir := archiveDir(t)
+	link := filepath.Join(t.TempDir(), "current")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+
+	path, remove, err := createArchive(context.Background(), link, archiveOptions{})
+	if remove != nil {
+		defer remove()
+	}
+	if err != nil {
+		t.Fatalf("failed to archive linked folder: %s", err)
+	}
+	if filepath.Base(path) != "current.zip" {
+		t.Errorf("expected the archive to be named after the link, got %s", filepath.Base(path))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := readArchive(t, data, ArchiveZip)
+	if _, ok := entries["main.go"]; !ok || len(entries) < 10 {
+		t.Errorf("expected the linked folder's contents to be archived, got %d entries", len(entries))
+	}
+	if e := entries["link.go"]; e.mode&fs.ModeSymlink == 0 {
+		t.Errorf("expected links inside the folder to stay links, got %+v", e)
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	patterns := []string{"*.o", "build/cache", "vendor/"}
+	for rel, want := range map[string]bool{
+		"main.o":            true,
+		"src/lib/util.o":    true,
+		"build/cache":       true,
+		"vendor":            true,
+		"src/vendor":        true,
+		"build/output":      false,
+		"other/build/cache": false,
+		"main.go":           false,
+	} {
+		if got := excluded(rel, patterns); got != want {
+			t.Errorf("%s: expected excluded to be %t, got %t", rel, want, got)
+		}
+	}
+}
+
+func TestStreamArchive(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		io.WriteString(w, "https://files.example.com/abc/project.tar.gz\n")
+	}))
+	defer server.Close()
+
+	client, api := newTestClient(t)
+	client.cfg.FileSizeLimit = 10
+	client.cfg.Upload = UploadConfig{URL: server.URL}
+
+	msg := NewMessage("Sources").WithFile(archiveDir(t)).WithArchiveFormat(ArchiveTarGz).RespectGitignore()
+	if _, err := client.Send(context.Background(), msg, "alice"); err != nil {
+		t.Fatalf("failed to send directory: %s", err)
+	}
+
+	if gotPath != "/project.tar.gz" {
+		t.Errorf("wrong upload path, expected /project.tar.gz, got %s", gotPath)
+	}
+	if entries := readArchive(t, gotBody, ArchiveTarGz); len(entries) != 11 {
+		t.Errorf("expected 11 files in the uploaded archive, got %v", entries)
+	}
+	if calls := api.Calls("sendMessage"); len(calls) != 1 || !strings.HasSuffix(calls[0].Params["text"], "project.tar.gz") {
+		t.Errorf("expected a link to the archive to be sent, got %v", calls)
+	}
+}
+
+func TestSendCompressedArchive(t *testing.T) {
+	uploaded := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploaded = true
+		io.WriteString(w, "https://files.example.com/abc/logs.tar.gz\n")
+	}))
+	defer server.Close()
+
+	client, api := newTestClient(t)
+	client.cfg.FileSizeLimit = 10000
+	client.cfg.Upload = UploadConfig{URL: server.URL}
+
+	// The folder is larger than the limit, but its archive isn't.
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.log"), make([]byte, 100000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := NewMessage("Logs").WithFile(dir).WithArchiveFormat(ArchiveTarGz)
+	if _, err := client.Send(context.Background(), msg, "alice"); err != nil {
+		t.Fatalf("failed to send directory: %s", err)
+	}
+	if uploaded {
+		t.Errorf("expected the archive to be sent via Telegram, it was uploaded")
+	}
+	if docs := api.Calls("sendDocument"); len(docs) != 1 {
+		t.Errorf("expected the archive to be sent as a document, got %d documents", len(docs))
+	}
+}
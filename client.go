@@ -0,0 +1,229 @@
+package tell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// Client sends messages through a Telegram bot to the users authorized in a config.
+type Client struct {
+	// Timeout limits how long a single request to Telegram or an upload backend may take, each retry gets a new one.
+	// Zero means no limit.
+	Timeout time.Duration
+	// Retries is how many times requests failing because of the network or the server are tried again.
+	Retries int
+	// Outbox, if set, keeps messages that couldn't be sent because of the network or the server, so that they can be
+	// delivered later with Flush.
+	Outbox *Outbox
+	// OnPartDownload, if set, is called by Download before each part of a split file is downloaded,
+	// with the part's number counting from 1 and the number of parts.
+	OnPartDownload func(part, parts int)
+
+	bot *gotgbot.Bot
+	cfg Config // A copy, changes the caller makes to its config don't affect the client
+}
+
+// SendResult is the outcome of sending a message to one recipient.
+type SendResult struct {
+	Recipient  Recipient
+	MessageIDs []int64 // A message can be sent as several Telegram messages, like a long text split into parts
+	Err        error
+	Queued     bool     // Sending failed, and the message was saved in the client's outbox instead
+	Fallbacks  []string // Why files Telegram rejected as their detected type were sent as documents instead
+
+	Type       MessageType   // What the message was sent as, after any fallbacks
+	FileIDs    []string      // Telegram's IDs for the files sent, which can be used to send them again
+	UploadURLs []string      // Where files too large for Telegram were uploaded
+	Duration   time.Duration // How long sending to the recipient took, not counting archiving and uploading
+}
+
+// NewClient creates a client for the bot in the config, and checks that its token is valid.
+func NewClient(cfg *Config) (*Client, error) {
+	if cfg.BotToken == "" {
+		return nil, fmt.Errorf("no bot token configured")
+	}
+
+	bot, err := newBot(cfg.BotToken, cfg.APIURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Timeout: DefaultTimeout, Retries: DefaultRetries, bot: bot, cfg: *cfg}, nil
+}
+
+// newBot creates a bot that talks to the Bot API server at apiURL, or to Telegram's if apiURL is empty.
+func newBot(token, apiURL string) (*gotgbot.Bot, error) {
+	if apiURL == "" {
+		apiURL = gotgbot.DefaultAPIURL
+	}
+
+	return gotgbot.NewBot(token, &gotgbot.BotOpts{
+		Client:             http.Client{Transport: statusTransport{http.DefaultTransport}},
+		DefaultRequestOpts: &gotgbot.RequestOpts{APIURL: apiURL},
+		// The token is checked with getMe, which can be slow.
+		RequestOpts: &gotgbot.RequestOpts{APIURL: apiURL, Timeout: 10 * time.Second},
+	})
+}
+
+// Bot returns the underlying bot, for using parts of the Bot API the client doesn't cover.
+func (c *Client) Bot() *gotgbot.Bot {
+	return c.bot
+}
+
+// retryPolicy returns the client's timeout and retry settings.
+func (c *Client) retryPolicy() retryPolicy {
+	return retryPolicy{retries: c.Retries, timeout: c.Timeout}
+}
+
+// Send sends a message to the recipients with the given IDs, or to all authorized users if there are none.
+// Directories are archived and large files uploaded only once, no matter how many recipients there are.
+//
+// There's one result for each recipient, in the same order. The error is non-nil if sending to any recipient failed,
+// and the message wasn't queued in the client's outbox. Messages with a reader attached are never queued.
+// If the message is invalid, there are no results.
+//
+// Failed requests are retried as set by the client's Timeout and Retries. Cancelling ctx stops sending, and removes
+// any temporary files, like archives of directories.
+func (c *Client) Send(ctx context.Context, msg *Message, to ...string) ([]SendResult, error) {
+	results, err := c.send(ctx, msg, to)
+	if c.Outbox == nil || results == nil || ctx.Err() != nil || msg.reader != nil {
+		return results, err
+	}
+
+	var queue []string
+	for _, r := range results {
+		if _, temporary := retryDelay(r.Err); temporary {
+			queue = append(queue, r.Recipient.ID)
+		}
+	}
+	if len(queue) == 0 {
+		return results, err
+	}
+
+	if queueErr := c.Outbox.Add(msg, queue); queueErr != nil {
+		return results, errors.Join(err, fmt.Errorf("failed to queue message: %w", queueErr))
+	}
+
+	var errs []error
+	for i, r := range results {
+		if _, temporary := retryDelay(r.Err); temporary {
+			results[i].Queued = true
+		} else if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Recipient.ID, r.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// send sends a message like Send, without queueing it when it fails.
+func (c *Client) send(ctx context.Context, msg *Message, to []string) ([]SendResult, error) {
+	routeIndex, err := c.cfg.Route(msg)
+	if err != nil {
+		return nil, err
+	}
+	var route *Route
+	if routeIndex >= 0 {
+		route = &c.cfg.Routes[routeIndex]
+	}
+	if len(to) == 0 && route != nil {
+		to = route.To
+	}
+
+	recipients, err := c.cfg.LookupRecipients(to)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no authorized users to send the message to")
+	}
+
+	msg = c.prepareMessage(msg)
+	if route != nil && !msg.optionsSet {
+		msg.options = route.Options(msg.options)
+	}
+	if err := msg.Check(msg.limits); err != nil {
+		return nil, err
+	}
+
+	if msg.encrypt {
+		if c.cfg.EncryptionKey == "" {
+			return nil, fmt.Errorf("no encryption key configured")
+		}
+		if msg.encryptKey, err = decodeEncryptionKey(c.cfg.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if msg.typ == FileUploadMessage || msg.typ == DirectoryMessage || msg.typ == AlbumMessage || msg.encrypt || msg.reader != nil {
+		// Archives and encrypted files may turn out to be too large once they're created.
+		if msg.uploader, err = newUploader(c.cfg.Upload); err != nil {
+			return nil, fmt.Errorf("invalid upload configuration: %w", err)
+		}
+	}
+
+	chatIDs := make([]int64, len(recipients))
+	for i, r := range recipients {
+		chatIDs[i] = r.ChatID
+	}
+
+	if err := registerButtons(msg, chatIDs); err != nil {
+		return nil, err
+	}
+
+	cleanup, prepareErr := msg.prepare(ctx)
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	results := make([]SendResult, len(recipients))
+	var errs []error
+	for i, r := range recipients {
+		// A message that couldn't be prepared, like a file that failed to upload, fails for everyone.
+		results[i] = SendResult{Recipient: r, Err: prepareErr}
+		if prepareErr == nil {
+			start := time.Now()
+			msg.resetSent()
+			results[i].MessageIDs, results[i].Err = msg.send(ctx, c.bot, r.ChatID)
+			results[i].Duration = time.Since(start)
+			results[i].Fallbacks = msg.fallbacks()
+			results[i].Type = msg.sentType()
+			results[i].FileIDs = msg.sentFileIDs()
+			results[i].UploadURLs = msg.uploadURLs()
+		}
+		if results[i].Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.ID, results[i].Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// registerButtons registers the actions of the message's buttons, so that they can be pressed in the given chats.
+func registerButtons(msg *Message, chatIDs []int64) error {
+	if len(msg.buttons) == 0 {
+		return nil
+	}
+	actionsPath, err := DefaultActionsPath()
+	if err == nil {
+		err = registerActions(actionsPath, msg.buttons, chatIDs)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register button actions: %w", err)
+	}
+	return nil
+}
+
+// prepareMessage returns a copy of the message to send through the client, with the client's limits and retries,
+// and the default options from the config unless the message has its own.
+func (c *Client) prepareMessage(msg *Message) *Message {
+	msg = msg.clone()
+	msg.limits = c.cfg.SizeLimits()
+	msg.retry = c.retryPolicy()
+	if !msg.optionsSet {
+		msg.options = c.cfg.Defaults
+	}
+	return msg
+}
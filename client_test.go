@@ -0,0 +1,139 @@
+package tell
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikolysz/tell/internal/fakebot"
+)
+
+// newTestClient creates a client that talks to a fake Bot API server, with alice (chat 1) and bob (chat 2) authorized.
+func newTestClient(t *testing.T) (*Client, *fakebot.Server) {
+	t.Helper()
+
+	api := fakebot.New(t)
+	client, err := NewClient(&Config{
+		BotToken:   fakebot.Token,
+		APIURL:     api.URL,
+		Recipients: map[string]int64{"alice": 1, "bob": 2},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	return client, api
+}
+
+func TestSendText(t *testing.T) {
+	client, api := newTestClient(t)
+
+	msg := NewMessage("Build *finished*").WithFormat(MarkdownV2)
+	results, err := client.Send(context.Background(), msg, "bob")
+	if err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+
+	if len(results) != 1 || results[0].Recipient.ID != "bob" || len(results[0].MessageIDs) != 1 {
+		t.Errorf("wrong results, expected one message sent to bob, got %+v", results)
+	}
+
+	calls := api.Calls("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 sendMessage call, got %d", len(calls))
+	}
+
+	want := map[string]string{"chat_id": "2", "text": "Build *finished*", "parse_mode": "MarkdownV2"}
+	for name, value := range want {
+		if calls[0].Params[name] != value {
+			t.Errorf("wrong %s, expected %q, got %q", name, value, calls[0].Params[name])
+		}
+	}
+}
+
+func TestSendLongText(t *testing.T) {
+	client, api := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // Button actions are stored in the home directory
+
+	text := strings.Repeat("line of output\n", 600)
+	msg := NewMessage(text).WithButton("Retry", "make")
+	results, err := client.Send(context.Background(), msg, "alice")
+	if err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+
+	calls := api.Calls("sendMessage")
+	if len(calls) < 2 {
+		t.Fatalf("expected the text to be split into several messages, got %d", len(calls))
+	}
+	if len(results[0].MessageIDs) != len(calls) {
+		t.Errorf("expected an ID for each of the %d messages, got %v", len(calls), results[0].MessageIDs)
+	}
+	for i, c := range calls {
+		if _, ok := c.Params["reply_markup"]; ok != (i == len(calls)-1) {
+			t.Errorf("buttons should only be on the last message, message %d has them: %t", i, ok)
+		}
+	}
+
+	msg = NewMessage(text).WithOverflow(OverflowFile)
+	if _, err := client.Send(context.Background(), msg, "alice"); err != nil {
+		t.Fatalf("failed to send message as a file: %s", err)
+	}
+
+	docs := api.Calls("sendDocument")
+	if len(docs) != 1 || string(docs[0].Files["document"].Data) != text {
+		t.Fatalf("expected the text to be sent as a single file, got %v", docs)
+	}
+	if !strings.HasSuffix(docs[0].Params["caption"], "…") {
+		t.Errorf("expected a preview in the caption, got %q", docs[0].Params["caption"])
+	}
+}
+
+func TestSendDocument(t *testing.T) {
+	client, api := newTestClient(t)
+
+	msg := NewMessage("Report").WithFile("testdata/foo")
+	if _, err := client.Send(context.Background(), msg, "alice"); err != nil {
+		t.Fatalf("failed to send document: %s", err)
+	}
+
+	calls := api.Calls("sendDocument")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 sendDocument call, got %d", len(calls))
+	}
+
+	file, ok := calls[0].Files["document"]
+	if !ok || file.Name != "foo" {
+		t.Errorf("file not sent in the multipart body, got %v", calls[0].Files)
+	}
+	if calls[0].Params["caption"] != "Report" {
+		t.Errorf("wrong caption, expected %q, got %q", "Report", calls[0].Params["caption"])
+	}
+
+	if _, err := client.Send(context.Background(), NewMessage("").WithFile("testdata"), "alice"); err != nil {
+		t.Fatalf("failed to send directory: %s", err)
+	}
+	if calls := api.Calls("sendDocument"); len(calls) != 2 || calls[1].Files["document"].Name != "testdata.zip" {
+		t.Errorf("directory not sent as a zip archive, got %v", calls)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	client, api := newTestClient(t)
+
+	api.Fail("sendMessage", 403, "Forbidden: bot was blocked by the user")
+
+	results, err := client.Send(context.Background(), NewMessage("Hello"))
+	if err == nil || !strings.Contains(err.Error(), "alice") {
+		t.Errorf("expected an error naming alice, got %v", err)
+	}
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "blocked") {
+		t.Errorf("expected sending to alice to fail with the injected error, got %v", results[0].Err)
+	}
+	if results[1].Err != nil {
+		t.Errorf("expected sending to bob to succeed, got %s", results[1].Err)
+	}
+
+	if _, err := client.Send(context.Background(), NewMessage("Hello"), "carol"); err == nil {
+		t.Errorf("sending to an unknown recipient succeeded")
+	}
+}
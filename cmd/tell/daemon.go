@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
+
+	"github.com/mikolysz/tell"
+)
+
+const (
+	// How long to collect a script's output before sending it, so that chatty scripts don't send a message per line.
+	outputFlushInterval = 2 * time.Second
+	// Telegram doesn't accept text messages longer than 4096 characters, stay a bit below that.
+	outputChunkSize = 4000
+	// How often to try delivering messages from the outbox.
+	outboxFlushInterval = time.Minute
+)
+
+// scriptNamePattern matches the script names users are allowed to ask for.
+// Anything else, in particular anything containing a path separator or "..", is rejected.
+var scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// daemon runs predefined scripts from the tellscripts directory when an authorized user sends '/name args' to the bot,
+// and the commands of notification buttons when they are pressed. Messages queued in the client's outbox are
+// delivered as soon as Telegram can be reached.
+// It blocks until ctx is cancelled, and then stops any scripts that are still running.
+func daemon(ctx context.Context, client *tell.Client, cfg *tell.Config, scriptTimeout time.Duration) error {
+	bot := client.Bot()
+	dir, err := defaultScriptsPath()
+	if err != nil {
+		return err
+	}
+
+	actionsPath, err := tell.DefaultActionsPath()
+	if err != nil {
+		return err
+	}
+
+	updater := ext.NewUpdater(nil)
+	handler := handlers.NewMessage(message.Command, func(b *gotgbot.Bot, c *ext.Context) error {
+		if !cfg.IsAuthorized(c.EffectiveChat.Id) {
+			log.Printf("Ignoring command from unauthorized chat %d", c.EffectiveChat.Id)
+			return nil
+		}
+
+		name, args, ok := parseCommand(c.EffectiveMessage.Text, b.Username)
+		if !ok {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, scriptTimeout)
+		defer cancel()
+
+		if err := runScript(ctx, b, c.EffectiveChat.Id, dir, name, args); err != nil {
+			log.Printf("Failed to run /%s: %s", name, err)
+			if _, err := b.SendMessage(c.EffectiveChat.Id, fmt.Sprintf("Could not run /%s: %s", name, err), nil); err != nil {
+				log.Printf("Failed to report error: %s", err)
+			}
+		}
+		return nil
+	})
+	updater.Dispatcher.AddHandler(handler)
+	updater.Dispatcher.AddHandler(handlers.NewCallback(nil, func(b *gotgbot.Bot, c *ext.Context) error {
+		return handleButton(ctx, b, c.CallbackQuery, cfg, actionsPath, scriptTimeout)
+	}))
+
+	err = updater.StartPolling(bot, &ext.PollingOpts{
+		GetUpdatesOpts: gotgbot.GetUpdatesOpts{
+			Timeout:        10,
+			AllowedUpdates: []string{"message", "callback_query"},
+			RequestOpts: &gotgbot.RequestOpts{
+				Timeout: 11 * time.Second,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start polling: %w", err)
+	}
+
+	log.Printf("Waiting for commands for @%s, scripts are loaded from %s", bot.Username, dir)
+	if client.Outbox != nil {
+		flushPeriodically(ctx, client)
+	} else {
+		<-ctx.Done()
+	}
+	log.Printf("Shutting down")
+
+	// Stop waits for running handlers, which return soon, as their scripts are killed when ctx is cancelled.
+	return updater.Stop()
+}
+
+// flushPeriodically delivers the messages in the client's outbox until ctx is cancelled.
+func flushPeriodically(ctx context.Context, client *tell.Client) {
+	ticker := time.NewTicker(outboxFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		report, err := client.Flush(ctx, client.Outbox)
+		if report.Delivered+report.Failed+report.Expired > 0 {
+			log.Printf("Outbox: %s", formatFlushReport(report))
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Could not deliver queued messages: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func defaultScriptsPath() (string, error) {
+	dirname, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	return filepath.Join(dirname, "tellscripts"), nil
+}
+
+// parseCommand splits a message like '/name@bot arg1 arg2' into the command name and its arguments.
+// It returns false if the message isn't a command, or is a command meant for another bot.
+func parseCommand(text, botUsername string) (name string, args []string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil, false
+	}
+
+	name = strings.TrimPrefix(fields[0], "/")
+	if i := strings.Index(name, "@"); i >= 0 {
+		if !strings.EqualFold(name[i+1:], botUsername) {
+			return "", nil, false
+		}
+		name = name[:i]
+	}
+
+	return name, fields[1:], true
+}
+
+// findScript finds the script in dir that should handle the command with the given name.
+// Scripts are matched by their name without the extension, so 'test' matches 'test.py'.
+func findScript(dir, name string) (string, error) {
+	if !scriptNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid script name %q", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read scripts directory: %w", err)
+	}
+
+	var found string
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if strings.TrimSuffix(fileName, filepath.Ext(fileName)) != name {
+			continue
+		}
+
+		// Follow symlinks, so that existing commands can be linked into the directory.
+		path := filepath.Join(dir, fileName)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		if found != "" {
+			return "", fmt.Errorf("more than one script called %s", name)
+		}
+		found = path
+	}
+
+	if found == "" {
+		return "", fmt.Errorf("no script called %s", name)
+	}
+	return found, nil
+}
+
+// runScript runs a script from dir, sending its output to the given chat while it runs.
+func runScript(ctx context.Context, bot *gotgbot.Bot, chatID int64, dir, name string, args []string) error {
+	path, err := findScript(dir, name)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Running %s %s", path, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	return runAndReport(ctx, bot, chatID, "/"+name, cmd)
+}
+
+// handleButton runs the action of a pressed notification button.
+// Only presses from authorized chats the button was actually sent to are accepted.
+func handleButton(ctx context.Context, bot *gotgbot.Bot, query *gotgbot.CallbackQuery, cfg *tell.Config, actionsPath string, timeout time.Duration) error {
+	// Without the message, we can't tell which chat the button was pressed in.
+	if query.Message == nil || !cfg.IsAuthorized(query.Message.Chat.Id) {
+		log.Printf("Ignoring button press from unauthorized user %d", query.From.Id)
+		_, err := bot.AnswerCallbackQuery(query.Id, &gotgbot.AnswerCallbackQueryOpts{Text: "Not authorized"})
+		return err
+	}
+	chatID := query.Message.Chat.Id
+
+	command, err := tell.LookupAction(actionsPath, query.Data, chatID)
+	if err != nil {
+		log.Printf("Ignoring button press in chat %d: %s", chatID, err)
+		_, err := bot.AnswerCallbackQuery(query.Id, &gotgbot.AnswerCallbackQueryOpts{Text: "This button doesn't work anymore"})
+		return err
+	}
+
+	if _, err := bot.AnswerCallbackQuery(query.Id, &gotgbot.AnswerCallbackQueryOpts{Text: "Running " + command}); err != nil {
+		log.Printf("Failed to answer button press: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("Running %s", command)
+	if err := runAndReport(ctx, bot, chatID, command, shellCommand(ctx, command)); err != nil {
+		log.Printf("Failed to run %s: %s", command, err)
+	}
+	return nil
+}
+
+// shellCommand returns a command that runs the given command line with the system shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// runAndReport runs cmd, sending its output to the given chat while it runs, and a status message when it's done.
+// The command should be created with ctx, so that it's stopped when ctx is cancelled.
+func runAndReport(ctx context.Context, bot *gotgbot.Bot, chatID int64, title string, cmd *exec.Cmd) error {
+	// Don't wait forever for output if the command left children behind that keep its stdout open.
+	cmd.WaitDelay = 5 * time.Second
+
+	output, outputWriter := io.Pipe()
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", title, err)
+	}
+
+	streamed := make(chan struct{})
+	go func() {
+		defer close(streamed)
+		streamOutput(output, func(text string) {
+			if _, err := bot.SendMessage(chatID, text, nil); err != nil {
+				log.Printf("Failed to send output of %s: %s", title, err)
+			}
+		})
+	}()
+
+	err := cmd.Wait()
+	outputWriter.Close()
+	<-streamed
+
+	status := "finished successfully"
+	if err != nil {
+		status = "failed: " + err.Error()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			status = "timed out and was stopped"
+		}
+	}
+
+	if _, err := bot.SendMessage(chatID, fmt.Sprintf("%s %s", title, status), nil); err != nil {
+		return fmt.Errorf("failed to send status: %w", err)
+	}
+	return nil
+}
+
+// streamOutput reads r until EOF, passing the output to send in chunks.
+// Output is sent at most every outputFlushInterval, or earlier once a chunk is full.
+func streamOutput(r io.Reader, send func(string)) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				lines <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(outputFlushInterval)
+	defer ticker.Stop()
+
+	var buf strings.Builder
+	flush := func() {
+		if text := strings.TrimRight(buf.String(), "\n"); text != "" {
+			send(text)
+		}
+		buf.Reset()
+	}
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				flush()
+				return
+			}
+
+			if buf.Len()+len(line) > outputChunkSize {
+				flush()
+			}
+			// A single line may be too long for a message on its own.
+			for len(line) > outputChunkSize {
+				cut := outputChunkSize
+				for !utf8.RuneStart(line[cut]) {
+					cut--
+				}
+				send(line[:cut])
+				line = line[cut:]
+			}
+			buf.WriteString(line)
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
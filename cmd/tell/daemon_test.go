@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindScript(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"test.py", "backup.sh", "backup.py", "noext"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatalf("failed to create script: %s", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+
+	for name, want := range map[string]string{"test": "test.py", "noext": "noext"} {
+		path, err := findScript(dir, name)
+		if err != nil {
+			t.Errorf("failed to find script %s: %s", name, err)
+		} else if filepath.Base(path) != want {
+			t.Errorf("wrong script found for %s, expected %s, got %s", name, want, path)
+		}
+	}
+
+	invalid := []string{
+		"backup",      // two scripts with the same name
+		"missing",     // no such script
+		"subdir",      // directories aren't scripts
+		"../test",     // path traversal
+		"subdir/test", // path separators
+		"",            // no name at all
+		"test.py",     // extensions are not part of the name
+		"/etc/passwd", // absolute paths
+		strings.Repeat("..", 3),
+	}
+	for _, name := range invalid {
+		if path, err := findScript(dir, name); err == nil {
+			t.Errorf("script found for invalid name %q: %s", name, path)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	name, args, ok := parseCommand("/deploy@TellBot  prod --force", "tellbot")
+	if !ok || name != "deploy" || !reflect.DeepEqual(args, []string{"prod", "--force"}) {
+		t.Errorf("wrong parse result: %q %q %v", name, args, ok)
+	}
+
+	if _, _, ok := parseCommand("/deploy@otherbot", "tellbot"); ok {
+		t.Errorf("command for another bot accepted")
+	}
+
+	if _, _, ok := parseCommand("deploy", "tellbot"); ok {
+		t.Errorf("message without a slash accepted as a command")
+	}
+}
+
+func TestStreamOutput(t *testing.T) {
+	long := strings.Repeat("x", outputChunkSize+10)
+	input := "first\nsecond\n" + long + "\n"
+
+	var chunks []string
+	streamOutput(strings.NewReader(input), func(text string) {
+		chunks = append(chunks, text)
+	})
+
+	for _, c := range chunks {
+		if len(c) > outputChunkSize {
+			t.Errorf("chunk longer than %d bytes: %d", outputChunkSize, len(c))
+		}
+	}
+
+	if got := strings.Join(chunks, "\n"); strings.ReplaceAll(got, "\n", "") != strings.ReplaceAll(input, "\n", "") {
+		t.Errorf("output lost or reordered: %q", chunks)
+	}
+}
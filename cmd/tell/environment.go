@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/mikolysz/tell"
+)
+
+// environment contains the flags and arguments passed to the program
+type environment struct {
+	token            string
+	authorizeNewUser bool
+	receive          bool
+	daemon           bool
+	flush            bool          // Deliver the messages queued in the outbox
+	queueStatus      bool          // List the messages queued in the outbox
+	noQueue          bool          // Don't queue messages that can't be sent
+	maxAge           time.Duration // How old queued messages may get before they're dropped
+	scriptTimeout    time.Duration // How long scripts run by the daemon may take
+	recipientID      string        // The ID to give to a newly authorized user
+	to               []string      // IDs of the users to send the message to, all users if empty
+	noUpload         bool          // If the file is too big, error out instead of uploading to transfer.sh
+	uploadBackend    string        // Overrides the upload backend from the config
+	timeout          time.Duration // How long a single request may take before it's retried
+	retries          int           // How many times failed requests are retried
+	encrypt          bool
+	format           tell.Format
+	code             bool
+	command          []string // The command to run before notifying, if any
+	tailLines        int      // How many lines of the command's output to include in the notification
+	stdinName        string   // If set, stdin is sent as a file with this name
+	json             bool     // Write the results of sending to stdout as JSON
+	verbose          bool     // Report how files were sent, like ones Telegram rejected being sent as documents
+	editID           int64    // If set, the text of this message is replaced instead of sending a new one
+	deleteID         int64    // If set, this message is deleted instead of sending one
+	replyTo          int64    // The message the new one replies to, if any
+	pin              bool     // Pin the message that's sent or edited
+	unpin            bool     // Unpin the message that's edited
+	live             bool     // Keep editing a single message with what's read from stdin
+	silent           *bool    // The send options given on the command line, nil for the config's defaults
+	protect          *bool
+	noPreview        *bool
+	spoiler          *bool
+	level            tell.Level // How severe the message is, for the routing rules in the config
+	tags             []string   // Tags for the routing rules in the config
+	explain          bool       // Show how the message would be routed instead of sending it
+
+	msg *tell.Message
+}
+
+// initEnvironment parses the command line. Files are sent as uploads or documents if they're larger than the given limits.
+func initEnvironment(args []string, limits tell.SizeLimits) (*environment, error) {
+	// set up and parse flags
+	//
+	// We continue on error here so that tests can run.
+	// main catches the error, and we exit there.
+	fs := flag.NewFlagSet("tell", flag.ContinueOnError)
+	env := &environment{}
+
+	var (
+		files []string
+		split bool
+	)
+
+	fs.StringVarP(&env.token, "token", "t", "", "Save the provided Telegram bot token in the config file")
+	fs.BoolVarP(&env.authorizeNewUser, "authorize-user", "a", false, "Authorize a new user to use the bot")
+	fs.StringVar(&env.recipientID, "id", "", "The ID to give to the user authorized with -a. Generated automatically if omitted")
+	fs.StringSliceVar(&env.to, "to", nil, "Comma-separated IDs of the users to send the message to. Defaults to all authorized users")
+	fs.BoolVarP(&env.receive, "receive", "r", false, "Print the last message sent to the bot and download any attached file")
+	fs.BoolVarP(&env.daemon, "daemon", "d", false, "Run in the background, executing scripts from ~/tellscripts when authorized users send /name to the bot")
+	fs.BoolVar(&env.flush, "flush", false, "Send the messages that were queued because Telegram couldn't be reached")
+	fs.BoolVar(&env.queueStatus, "queue-status", false, "List the messages waiting to be sent")
+	fs.BoolVar(&env.noQueue, "no-queue", false, "If Telegram can't be reached, fail instead of queueing the message to be sent later")
+	fs.DurationVar(&env.maxAge, "max-age", tell.DefaultOutboxMaxAge, "How old queued messages may get before they're dropped instead of sent by --flush or the daemon. 0 means no limit")
+	fs.DurationVar(&env.scriptTimeout, "script-timeout", 10*time.Minute, "How long a script run by the daemon may take before it's stopped")
+	fs.StringVar(&env.stdinName, "stdin-as-file", "", "Send standard input as a file with the given name, like 'dump.sql', without reading it all into memory first")
+	fs.StringArrayVarP(&files, "file", "f", nil, "Send the provided file. Can be repeated, or a quoted glob like '*.png', to send several files, grouped into albums where possible")
+	format := fs.String("format", "plain", "How to format the message. One of: plain, markdown (Telegram's MarkdownV2) or html")
+	fs.BoolVar(&env.code, "code", false, "Send the message as a preformatted code block, keeping its alignment")
+	overflow := fs.String("overflow", string(tell.OverflowSplit), "What to do with text that is too long for a single message. One of: split (send several messages) or file (send it as a text file)")
+	buttons := fs.StringArrayP("button", "o", nil, "Add a button to the message, in the form 'Label: command'. The command is run by 'tell -d' when the button is pressed. Can be repeated")
+	fileType := fs.String("file-type", "", "The type of file to send. One of: animation, audio, document, photo, sticker, video, video_note, voice or upload. Will be detected automatically if omitted")
+	fs.BoolVarP(&env.noUpload, "no-upload", "n", false, "Do not upload files to transfer.sh if they are too big")
+	fs.BoolVarP(&env.encrypt, "encrypt", "e", false, "Encrypt the file with the key from the config. It's sent as a document, and decrypted automatically by 'tell -r'")
+	archive := fs.String("archive", string(tell.ArchiveZip), "The kind of archive folders are sent as. One of: zip, tar.gz, tar.zst or tar")
+	exclude := fs.StringArray("exclude", nil, "Leave files matching the pattern, like 'node_modules' or '*.log', out of archived folders. Can be repeated")
+	gitignore := fs.Bool("gitignore", false, "Leave files ignored by git, and the .git directory, out of archived folders")
+	fs.BoolVar(&split, "split", false, "Split files that are too big for Telegram into parts instead of uploading them. 'tell -r' joins the parts again")
+	fs.StringVar(&env.uploadBackend, "upload-backend", "", "Where to upload files that are too big for Telegram. One of: transfer.sh, 0x0, s3, scp or rsync. Defaults to the backend in the config, or transfer.sh")
+	fs.DurationVar(&env.timeout, "timeout", tell.DefaultTimeout, "How long a single request to Telegram or an upload may take before it's given up on and retried. 0 means no limit")
+	fs.IntVar(&env.retries, "retries", tell.DefaultRetries, "How many times to retry requests that fail because of network or server problems")
+	fs.BoolVarP(&env.verbose, "verbose", "v", false, "Report files that were sent as documents because Telegram rejected their type")
+	fs.BoolVar(&env.json, "json", false, "After sending, print the results as JSON, with the IDs of the messages and files sent and where files were uploaded")
+	fs.Int64Var(&env.editID, "edit", 0, "Replace the text or caption of the message with this ID instead of sending a new one. Message IDs are printed by --json")
+	fs.Int64Var(&env.deleteID, "delete", 0, "Delete the message with this ID")
+	fs.Int64Var(&env.replyTo, "reply-to", 0, "Send the message as a reply to the message with this ID")
+	fs.BoolVar(&env.pin, "pin", false, "Pin the message that's sent, or the one edited with --edit")
+	fs.BoolVar(&env.unpin, "unpin", false, "Unpin the message edited with --edit")
+	fs.BoolVar(&env.live, "live", false, "Read standard input as it comes in, and keep editing a single message with the latest lines and the progress they report, like '45%'. A final status is sent when the input ends")
+	silent := fs.Bool("silent", false, "Deliver the message without a notification sound. Pass --silent=false to override a default from the config, like the other options below")
+	protect := fs.Bool("protect", false, "Keep the message from being forwarded or saved")
+	noPreview := fs.Bool("no-preview", false, "Don't show previews of links in the message")
+	spoiler := fs.Bool("spoiler", false, "Cover photos, videos and animations with a spoiler until they're tapped")
+	level := fs.String("level", "", "How severe the message is, for the routing rules in the config. One of: info, warn, error or critical")
+	fs.StringArrayVar(&env.tags, "tag", nil, "Tag the message for the routing rules in the config. Can be repeated")
+	fs.BoolVar(&env.explain, "explain", false, "Show which routing rule applies to the message, who would get it and how, without sending it")
+	fs.IntVar(&env.tailLines, "tail", 10, "Number of output lines to include in the notification when running a command with 'tell -- <command>', or in a --live message")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	env.silent = changedBool(fs, "silent", silent)
+	env.protect = changedBool(fs, "protect", protect)
+	env.noPreview = changedBool(fs, "no-preview", noPreview)
+	env.spoiler = changedBool(fs, "spoiler", spoiler)
+
+	// Validate the provided flags and arguments.
+	//
+	// Get the message from the command line
+	// This way, we can use tell like echo, without having to quote the message
+	args = fs.Args()
+	if dash := fs.ArgsLenAtDash(); dash >= 0 {
+		// Everything after "--" is a command to run, like in 'tell -- make release'.
+		env.command = args[dash:]
+		args = args[:dash]
+
+		if len(env.command) == 0 {
+			return nil, fmt.Errorf("No command specified after '--'")
+		}
+	}
+	text := strings.Join(args, " ")
+
+	if env.editID < 0 || env.deleteID < 0 || env.replyTo < 0 {
+		return nil, fmt.Errorf("Message IDs must be positive")
+	}
+
+	if env.timeout < 0 {
+		return nil, fmt.Errorf("--timeout must not be negative")
+	}
+	if env.retries < 0 {
+		return nil, fmt.Errorf("--retries must not be negative")
+	}
+
+	var err error
+	env.format, err = tell.ParseFormat(*format)
+	if err != nil {
+		return nil, err
+	}
+
+	if *level != "" {
+		if env.level, err = tell.ParseLevel(*level); err != nil {
+			return nil, err
+		}
+	}
+
+	if o := tell.Overflow(*overflow); o != tell.OverflowSplit && o != tell.OverflowFile {
+		return nil, fmt.Errorf("Invalid overflow policy: %s", *overflow)
+	}
+
+	env.msg = tell.NewMessage(text).WithFormat(env.format).WithOverflow(tell.Overflow(*overflow))
+	if env.code {
+		env.msg.AsCode()
+	}
+	if env.replyTo != 0 {
+		env.msg.ReplyTo(env.replyTo)
+	}
+	env.msg.WithLevel(env.level).WithTags(env.tags...)
+
+	for _, def := range *buttons {
+		label, command, err := parseButton(def)
+		if err != nil {
+			return nil, err
+		}
+		env.msg.WithButton(label, command)
+	}
+	// Whether any of the flags or arguments only make sense when sending a message.
+	sending := files != nil || env.stdinName != "" || text != "" || env.command != nil || env.to != nil || *buttons != nil ||
+		env.editID != 0 || env.deleteID != 0 || env.replyTo != 0 || env.pin || env.unpin || env.live ||
+		env.silent != nil || env.protect != nil || env.noPreview != nil || env.spoiler != nil || env.level != "" || env.tags != nil || env.explain
+
+	if env.token != "" || env.authorizeNewUser {
+		if sending {
+			return nil, fmt.Errorf("Cannot modify configuration and send a message at the same time")
+		}
+	}
+
+	if env.recipientID != "" && !env.authorizeNewUser {
+		return nil, fmt.Errorf("--id can only be used when authorizing a user with -a")
+	}
+
+	if env.json && (env.daemon || env.receive || env.flush || env.queueStatus || env.token != "" || env.authorizeNewUser || env.editID != 0 || env.deleteID != 0 || env.live) {
+		return nil, fmt.Errorf("--json can only be used when sending a new message")
+	}
+
+	if env.maxAge < 0 {
+		return nil, fmt.Errorf("--max-age must not be negative")
+	}
+
+	if env.flush || env.queueStatus {
+		if env.flush && env.queueStatus {
+			return nil, fmt.Errorf("Cannot use --flush and --queue-status at the same time")
+		}
+		if env.daemon || env.receive || env.token != "" || env.authorizeNewUser || sending {
+			return nil, fmt.Errorf("Cannot work with the outbox and send or receive messages or modify configuration at the same time")
+		}
+		return env, nil
+	}
+
+	if env.daemon {
+		if env.receive || env.token != "" || env.authorizeNewUser || sending {
+			return nil, fmt.Errorf("Cannot run the daemon and send messages or modify configuration at the same time")
+		}
+		if env.scriptTimeout <= 0 {
+			return nil, fmt.Errorf("--script-timeout must be positive")
+		}
+		return env, nil
+	}
+
+	if env.receive {
+		if env.token != "" || env.authorizeNewUser || sending {
+			return nil, fmt.Errorf("Cannot receive and send messages or modify configuration at the same time")
+		}
+		return env, nil
+	}
+
+	if env.explain && (env.json || env.editID != 0 || env.deleteID != 0) {
+		return nil, fmt.Errorf("Cannot use --explain with --json, --edit or --delete, it only shows how a new message would be sent")
+	}
+
+	// Message IDs are only unique within a chat, so a message can only be changed in one recipient's chat.
+	if (env.editID != 0 || env.deleteID != 0) && len(env.to) > 1 {
+		return nil, fmt.Errorf("--edit and --delete work on a single chat, choose one recipient with --to")
+	}
+
+	if env.deleteID != 0 {
+		if env.editID != 0 || text != "" || files != nil || env.stdinName != "" || env.command != nil || *buttons != nil || env.replyTo != 0 || env.pin || env.unpin || env.live {
+			return nil, fmt.Errorf("Cannot delete a message and send, edit or pin one at the same time")
+		}
+		return env, nil
+	}
+
+	if env.editID != 0 {
+		if files != nil || env.stdinName != "" {
+			return nil, fmt.Errorf("Cannot edit files, only the text or caption of a message")
+		}
+		if env.replyTo != 0 {
+			return nil, fmt.Errorf("Cannot use --reply-to with --edit, the message is already sent")
+		}
+		if env.pin && env.unpin {
+			return nil, fmt.Errorf("Cannot use --pin and --unpin at the same time")
+		}
+	} else if env.unpin {
+		return nil, fmt.Errorf("--unpin can only be used with --edit")
+	}
+
+	if env.live {
+		if files != nil || env.stdinName != "" || env.command != nil || *buttons != nil || env.editID != 0 || env.pin {
+			return nil, fmt.Errorf("Cannot use --live with files, commands, buttons, --edit or --pin")
+		}
+		if env.tailLines < 0 {
+			return nil, fmt.Errorf("--tail must not be negative")
+		}
+
+		// Standard input is read as it comes in, below the text given on the command line.
+		return env, nil
+	}
+
+	if env.command != nil {
+		if files != nil || env.stdinName != "" {
+			return nil, fmt.Errorf("Cannot run a command and send a file at the same time")
+		}
+		if env.tailLines < 0 {
+			return nil, fmt.Errorf("--tail must not be negative")
+		}
+
+		// The command's output becomes the message, so stdin is left for the command to use.
+		return env, nil
+	}
+
+	// Standard input sent with --stdin-as-file takes the options of a file.
+	hasFile := files != nil || env.stdinName != ""
+
+	if files != nil && env.stdinName != "" {
+		return nil, fmt.Errorf("Cannot use --stdin-as-file and --file at the same time")
+	}
+
+	if !hasFile && *fileType != "" {
+		return nil, fmt.Errorf("Filetype is present, but no file was specified.")
+	}
+
+	if !hasFile && env.noUpload {
+		return nil, fmt.Errorf("Cannot use --no-upload without a file")
+	}
+
+	if env.noUpload && env.uploadBackend != "" {
+		return nil, fmt.Errorf("Cannot use --no-upload and --upload-backend at the same time")
+	}
+
+	if !hasFile && env.encrypt {
+		return nil, fmt.Errorf("Cannot use --encrypt without a file")
+	}
+
+	if !hasFile && *spoiler {
+		return nil, fmt.Errorf("Cannot use --spoiler without a file")
+	}
+
+	if !hasFile && split {
+		return nil, fmt.Errorf("Cannot use --split without a file")
+	}
+
+	if files == nil && (fs.Changed("archive") || len(*exclude) > 0 || *gitignore) {
+		return nil, fmt.Errorf("Cannot use --archive, --exclude or --gitignore without a file")
+	}
+
+	if split && env.uploadBackend != "" {
+		return nil, fmt.Errorf("Cannot use --split and --upload-backend at the same time")
+	}
+
+	if !hasFile {
+		if text == "" {
+			// If we have no message, read from stdin.
+			if err := readStdin(env.msg, os.Stdin); err != nil {
+				return nil, err
+			}
+		}
+
+		return env, nil
+	}
+
+	if env.stdinName != "" {
+		env.msg.WithReader(env.stdinName, os.Stdin)
+	} else {
+		paths, err := expandFiles(files)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			env.msg.WithFile(path)
+		}
+	}
+	if env.noUpload {
+		env.msg.WithoutUpload()
+	}
+	if split {
+		env.msg.SplitLargeFiles()
+	}
+	if env.encrypt {
+		env.msg.Encrypted()
+	}
+
+	archiveFormat, err := tell.ParseArchiveFormat(*archive)
+	if err != nil {
+		return nil, err
+	}
+	env.msg.WithArchiveFormat(archiveFormat).ExcludeFiles(*exclude...)
+	if *gitignore {
+		env.msg.RespectGitignore()
+	}
+
+	if *fileType != "" {
+		typ, err := tell.ParseMessageType(*fileType)
+		if err != nil {
+			return nil, err
+		}
+		env.msg.WithType(typ)
+	}
+
+	if err := env.msg.Check(limits); err != nil {
+		return nil, err
+	}
+	for _, warning := range env.msg.Warnings() {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+
+	if *fileType != "" && *fileType != tell.FileUploadMessage.String() && env.msg.Type() == tell.FileUploadMessage {
+		// Too large to send with the chosen file type.
+		if split {
+			fmt.Fprintln(os.Stderr, "Warning: File is too big, sending it in parts instead.")
+		} else {
+			fmt.Fprintln(os.Stderr, "Warning: File is too big, uploading it instead. Pass --no-upload to make this fail instead.")
+		}
+	}
+
+	return env, nil
+}
+
+// maxStdinText is the most input read from stdin that's sent as text. Longer input would be split into too many
+// messages to be readable, so it's sent as a file instead.
+const maxStdinText = 16 * 4096
+
+// readStdin makes what's read from r the text of msg. Binary or very long input is attached as a file instead,
+// in which case only its beginning is read here, and the rest while it's sent.
+func readStdin(msg *tell.Message, r io.Reader) error {
+	head, err := io.ReadAll(io.LimitReader(r, maxStdinText+1))
+	if err != nil {
+		return fmt.Errorf("failed to read from stdin: %w", err)
+	}
+
+	tooLong := len(head) > maxStdinText
+	binary := bytes.IndexByte(head, 0) >= 0 || (!tooLong && !utf8.Valid(head))
+	switch {
+	case binary:
+		fmt.Fprintln(os.Stderr, "Warning: Standard input contains binary data, sending it as a file instead.")
+		msg.WithReader("stdin", io.MultiReader(bytes.NewReader(head), r))
+	case tooLong:
+		fmt.Fprintln(os.Stderr, "Warning: Standard input is too long for a message, sending it as a file instead.")
+		msg.WithReader("stdin.txt", io.MultiReader(bytes.NewReader(head), r))
+	default:
+		msg.WithText(string(head))
+	}
+	return nil
+}
+
+// expandFiles expands the globs among the files given with -f, in case the shell didn't.
+// Paths that exist are used as they are, even if they look like a glob.
+func expandFiles(patterns []string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		if _, err := os.Lstat(pattern); err == nil || !strings.ContainsAny(pattern, `*?[\`) {
+			paths = append(paths, pattern)
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid file pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("No files match %s", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// parseButton parses a button definition like "Retry: make build".
+func parseButton(def string) (label, command string, err error) {
+	label, command, ok := strings.Cut(def, ":")
+	label, command = strings.TrimSpace(label), strings.TrimSpace(command)
+	if !ok || label == "" || command == "" {
+		return "", "", fmt.Errorf("invalid button %q, expected 'Label: command'", def)
+	}
+	return label, command, nil
+}
+
+// recipient returns the recipient chosen with --to, or an empty string for the only authorized user.
+// It's used where a message ID is given, as they're only unique within one chat.
+func (env *environment) recipient() string {
+	if len(env.to) == 1 {
+		return env.to[0]
+	}
+	return ""
+}
+
+// changedBool returns the value of a flag if it was given on the command line, nil otherwise.
+func changedBool(fs *flag.FlagSet, name string, value *bool) *bool {
+	if !fs.Changed(name) {
+		return nil
+	}
+	return value
+}
+
+// sendOptions returns the default options from the config, overridden by the ones given on the command line.
+func (env *environment) sendOptions(defaults tell.SendOptions) tell.SendOptions {
+	for _, o := range []struct {
+		flag   *bool
+		option *bool
+	}{
+		{env.silent, &defaults.Silent},
+		{env.protect, &defaults.Protect},
+		{env.noPreview, &defaults.NoPreview},
+		{env.spoiler, &defaults.Spoiler},
+	} {
+		if o.flag != nil {
+			*o.option = *o.flag
+		}
+	}
+	return defaults
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mikolysz/tell"
+)
+
+func TestValidArguments(t *testing.T) {
+	valid := []string{
+		"hello",
+		"-t token",
+		"-a",
+		"-f testdata/foo",
+		"-f testdata/foo Message caption",
+		"-f testdata/foo -f testdata/test.jpg Two files",
+		"-f testdata/* Everything in testdata",
+		"-f testdata/foo --file-type audio",
+		"-v -f testdata/test.jpg",
+		"-f testdata/foo --file-type photo My caption",
+		"-- make release",
+		"Release finished -- make release",
+		"--tail 20 -- make release",
+		"--timeout 30s --retries 0 hello",
+		"--flush",
+		"--flush --max-age 1h",
+		"--queue-status",
+		"--no-queue hello",
+		"-r",
+		"-a --id ops",
+		"--to ops,alice Hello",
+		"-d",
+		"-d --script-timeout 1h",
+		"-o Restart:make Build failed",
+		"-f testdata --split",
+		"--stdin-as-file dump.sql",
+		"--stdin-as-file dump.sql -e --split Nightly backup",
+		"-f testdata --archive tar.zst --exclude *.jpg --exclude build --gitignore",
+		"-ef testdata/foo",
+		"--format markdown *Build* failed",
+		"--format html --code Hello",
+		"--overflow file Hello",
+		"--edit 42 Deploy finished",
+		"--edit 42 --to ops --unpin Deploy finished",
+		"--edit 42 -- make release",
+		"--delete 42",
+		"--reply-to 42 --pin Deploy started",
+		"--live",
+		"--live --tail 5 --to ops Nightly import",
+		"--silent Nightly report",
+		"--silent=false --protect --no-preview https://example.com",
+		"-f testdata/test.jpg --spoiler",
+		"--level error --tag db --tag prod Disk full",
+		"--explain --level critical Hi",
+	}
+
+	for _, v := range valid {
+		t.Run("Args: "+v, func(t *testing.T) {
+			args := strings.Split(v, " ")
+			_, err := initEnvironment(args, tell.SizeLimits{})
+
+			if err != nil {
+				t.Errorf("validation failed for valid arguments: '%s': %s", v, err)
+			}
+		})
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	invalid := []string{
+		"-t token hello", // both a token and a message
+		"-a Hello",       // both a message and the authorize flag
+		"-f testdata/foo --file-type no_such_type",
+		"-f testdata/*.nothing",                              // the glob matches no files
+		"-f testdata/foo -f testdata/test.jpg -o Retry:make", // buttons can't go on albums
+		"--file-type photo caption",                          // There's a file type, but not a file.
+		"--no-upload",                                        // There's no file, so we can't upload it.
+		"-f testdata/foo --no-upload --file-type upload",
+		"-f testdata/foo --file-type voice This is a caption, but voice messages don't support captions.",
+		"-a -- make release",        // both a command and the authorize flag
+		"-f testdata/foo -- make",   // both a command and a file
+		"--",                        // no command after the dash
+		"--tail -1 -- make release", // negative number of lines
+		"--timeout -1s hello",       // negative timeout
+		"--retries -1 hello",        // negative number of retries
+		"--overflow truncate Hello", // unknown overflow policy
+		"-f testdata --archive rar", // unknown archive format
+		"--gitignore Hello",         // there's no folder to archive
+		"--stdin-as-file dump.sql -f testdata/foo",
+		"--stdin-as-file out.txt -- make",
+		"--edit 42 -f testdata/foo",      // files can't be edited
+		"--edit 42 --to ops,alice Hello", // the ID only means something in one chat
+		"--edit 42 --reply-to 41 Hello",  // the message was already sent
+		"--edit 42 --delete 42",          // both editing and deleting
+		"--delete 42 Hello",              // both deleting and sending
+		"--delete 42 --json",             // there are no results to print
+		"--unpin Hello",                  // there's no message to unpin
+		"--edit -1 Hello",                // negative message ID
+	}
+
+	for _, iv := range invalid {
+		t.Run("Args: "+iv, func(t *testing.T) {
+			args := strings.Split(iv, " ")
+			_, err := initEnvironment(args, tell.SizeLimits{})
+			if err == nil {
+				t.Errorf("validation succeeded for invalid arguments: '%s'", iv)
+			}
+		})
+	}
+}
+
+func TestSendOptions(t *testing.T) {
+	env, err := initEnvironment(strings.Split("--silent=false --protect Build failed", " "), tell.SizeLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := tell.SendOptions{Silent: true, NoPreview: true}
+	want := tell.SendOptions{Protect: true, NoPreview: true}
+	if got := env.sendOptions(defaults); got != want {
+		t.Errorf("expected the flags to override the defaults, got %+v", got)
+	}
+}
+
+func TestFiletypeDetection(t *testing.T) {
+	_, err := initEnvironment([]string{"-f", "this_file_does_not_exist"}, tell.SizeLimits{})
+	if err == nil {
+		t.Errorf("validation succeeded for non-existent file")
+	}
+
+	env, err := initEnvironment([]string{"-f", "testdata/foo"}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for existing file: %s", err)
+	}
+
+	if env.msg.Type() != tell.DocumentMessage {
+		t.Errorf("wrong type detected for file without extension, expected document, got %s", env.msg.Type())
+	}
+
+	env, err = initEnvironment([]string{"-f", "testdata/foo", "--file-type", "audio"}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for existing file %s when file type was explicitly specified", err)
+	}
+
+	if env.msg.Type() != tell.AudioMessage {
+		t.Errorf("wrong type detected for file with explicit audio type, expected audio, got %s", env.msg.Type())
+	}
+
+	env, err = initEnvironment([]string{"-f", "testdata"}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for directory: %s", err)
+	}
+
+	if env.msg.Type() != tell.DirectoryMessage {
+		t.Errorf("wrong type detected for directory, expected folder, got %s", env.msg.Type())
+	}
+
+	file, cleanup, err := tempFile("jpg", 42)
+	if err != nil {
+		t.Errorf("failed to create temporary photo file: %s", err)
+	}
+	defer cleanup()
+
+	env, err = initEnvironment([]string{"-f", file}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for photo: %s", err)
+	}
+
+	if env.msg.Type() != tell.PhotoMessage {
+		t.Errorf("wrong type detected for photo, expected photo, got %s", env.msg.Type())
+	}
+
+	file, cleanup, err = tempFile("jpg", 10*1024*1024+1)
+	if err != nil {
+		t.Errorf("failed to create temporary photo file: %s", err)
+	}
+	defer cleanup()
+
+	env, err = initEnvironment([]string{"-f", file}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for large photo: %s", err)
+	}
+	if env.msg.Type() != tell.DocumentMessage {
+		t.Errorf("wrong type detected for photo exceeding file size limit, expected document, got %s", env.msg.Type())
+	}
+
+	file, cleanupISO, err := tempFile("iso", 50*1024*1024+1)
+	if err != nil {
+		t.Errorf("failed to create large file: %s", err)
+	}
+	defer cleanupISO()
+
+	env, err = initEnvironment([]string{"-f", file}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for large file: %s", err)
+	}
+	if env.msg.Type() != tell.FileUploadMessage {
+		t.Errorf("wrong type detected for file exceeding file size limit, expected file upload, got %s", env.msg.Type())
+	}
+
+	env, err = initEnvironment([]string{"-f", file, "--file-type", "audio"}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for large file with explicit file type: %s", err)
+	}
+	if env.msg.Type() != tell.FileUploadMessage {
+		t.Errorf("Type not set to upload for large file with manual type override, expected file upload, got %s", env.msg.Type())
+	}
+
+	env, err = initEnvironment([]string{"-f", file}, tell.SizeLimits{File: 2000 * 1024 * 1024})
+	if err != nil {
+		t.Errorf("validation failed for large file with a local Bot API server: %s", err)
+	}
+	if env.msg.Type() != tell.DocumentMessage {
+		t.Errorf("wrong type detected for large file with a local Bot API server, expected document, got %s", env.msg.Type())
+	}
+
+	env, err = initEnvironment([]string{"-f", file, "--no-upload"}, tell.SizeLimits{})
+	if err == nil {
+		t.Errorf("validation succeeded for large file with no-upload flag")
+	}
+
+	env, err = initEnvironment([]string{"-f", file, "--no-upload", "--split"}, tell.SizeLimits{})
+	if err != nil {
+		t.Errorf("validation failed for large file with no-upload and split flags: %s", err)
+	}
+}
+
+func TestReadStdin(t *testing.T) {
+	cases := []struct {
+		input  string
+		asFile bool
+	}{
+		{"uptime: 12 days\n", false},
+		{strings.Repeat("x", maxStdinText), false},
+		{strings.Repeat("x", maxStdinText+1), true},
+		{"PGDMP\x00\x01binary", true},
+		{"\xff\xfe invalid UTF-8", true},
+	}
+
+	for i, c := range cases {
+		msg := tell.NewMessage("")
+		if err := readStdin(msg, strings.NewReader(c.input)); err != nil {
+			t.Errorf("case %d: failed to read stdin: %s", i, err)
+			continue
+		}
+		if err := msg.Check(tell.SizeLimits{}); err != nil {
+			t.Errorf("case %d: check failed: %s", i, err)
+			continue
+		}
+
+		if c.asFile && (msg.Type() != tell.DocumentMessage || msg.Text() != "") {
+			t.Errorf("case %d: expected the input to be sent as a file, got %s %q", i, msg.Type(), msg.Text())
+		}
+		if !c.asFile && msg.Text() != c.input {
+			t.Errorf("case %d: expected the input to be sent as text, got %s", i, msg.Type())
+		}
+	}
+}
+
+// tempFile creates a temporary file with the given size and extension.
+// The file is filled with garbage contents.
+// It returns the path to the file and a function that can be used to remove it.
+func tempFile(extension string, sizeBytes int) (string, func(), error) {
+	f, err := ioutil.TempFile("", "tell_test_*."+extension)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	remove := func() {
+		_ = os.Remove(f.Name())
+	}
+
+	// Fill the file with garbage.
+	_, err = f.Write(make([]byte, sizeBytes))
+	if err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	return f.Name(), remove, nil
+}
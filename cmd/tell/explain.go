@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mikolysz/tell"
+)
+
+// explain describes how the message would be sent: which routing rule applies to it, who would get it
+// and with which options. A command given after "--" isn't run, so rules matching its output can't be explained.
+func explain(w io.Writer, cfg *tell.Config, env *environment) error {
+	opts, index, err := routeOptions(cfg, env)
+	if err != nil {
+		return err
+	}
+
+	level := string(env.level)
+	if level == "" {
+		level = "none"
+	}
+	fmt.Fprintf(w, "Level: %s\n", level)
+	if len(env.tags) > 0 {
+		fmt.Fprintf(w, "Tags: %s\n", strings.Join(env.tags, ", "))
+	}
+
+	to := env.to
+	if index < 0 {
+		fmt.Fprintln(w, "Route: none of the rules apply")
+	} else {
+		route := &cfg.Routes[index]
+		fmt.Fprintf(w, "Route: %s%s\n", route.DisplayName(index), describeRoute(route))
+		if len(to) == 0 {
+			to = route.To
+		}
+	}
+
+	recipients, err := cfg.LookupRecipients(to)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(recipients))
+	for i, r := range recipients {
+		ids[i] = r.ID
+	}
+	source := ""
+	if len(env.to) > 0 {
+		source = " (chosen with --to)"
+	}
+	fmt.Fprintf(w, "Recipients: %s%s\n", strings.Join(ids, ", "), source)
+
+	var names []string
+	for _, o := range []struct {
+		set  bool
+		name string
+	}{{opts.Silent, "silent"}, {opts.Protect, "protected"}, {opts.NoPreview, "no link previews"}, {opts.Spoiler, "spoiler"}} {
+		if o.set {
+			names = append(names, o.name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"none"}
+	}
+	fmt.Fprintf(w, "Options: %s\n", strings.Join(names, ", "))
+	return nil
+}
+
+// describeRoute lists a routing rule's conditions, in parentheses.
+func describeRoute(r *tell.Route) string {
+	var conditions []string
+	if r.Level != "" {
+		conditions = append(conditions, fmt.Sprintf("level %s or above", r.Level))
+	}
+	if r.Tag != "" {
+		conditions = append(conditions, "tag "+r.Tag)
+	}
+	if r.Match != "" {
+		conditions = append(conditions, fmt.Sprintf("text matching %q", r.Match))
+	}
+	if len(conditions) == 0 {
+		return " (applies to every message)"
+	}
+	return " (" + strings.Join(conditions, ", ") + ")"
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mikolysz/tell"
+)
+
+func TestExplain(t *testing.T) {
+	quiet := true
+	cfg := &tell.Config{
+		Recipients: map[string]int64{"alice": 1, "bob": 2, "ops": 3},
+		Defaults:   tell.SendOptions{NoPreview: true},
+		Routes: []tell.Route{
+			{Name: "pager", Level: tell.LevelError, Tag: "prod", To: []string{"bob", "ops"}},
+			{Match: "(?i)report", To: []string{"alice"}, Silent: &quiet},
+		},
+	}
+
+	for _, tt := range []struct {
+		args string
+		want string
+	}{
+		{"--level critical --tag prod Disk full", "Level: critical\nTags: prod\nRoute: pager (level error or above, tag prod)\nRecipients: bob, ops\nOptions: no link previews\n"},
+		{"Nightly report", "Level: none\nRoute: #2 (text matching \"(?i)report\")\nRecipients: alice\nOptions: silent, no link previews\n"},
+		{"--to ops --silent=false --no-preview=false Nightly report", "Level: none\nRoute: #2 (text matching \"(?i)report\")\nRecipients: ops (chosen with --to)\nOptions: none\n"},
+		{"--level warn Disk full", "Level: warn\nRoute: none of the rules apply\nRecipients: alice, bob, ops\nOptions: no link previews\n"},
+	} {
+		env, err := initEnvironment(strings.Split("--explain "+tt.args, " "), tell.SizeLimits{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := explain(&out, cfg, env); err != nil {
+			t.Fatalf("%s: %s", tt.args, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.args, tt.want, out.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/mikolysz/tell"
+)
+
+// jsonResults is what --json prints after sending a message.
+type jsonResults struct {
+	OK        bool         `json:"ok"`
+	Error     string       `json:"error,omitempty"`
+	ElapsedMS int64        `json:"elapsed_ms"` // How long sending took in total, including archiving and uploading
+	Results   []jsonResult `json:"results"`
+}
+
+// jsonResult describes what was sent to one recipient.
+type jsonResult struct {
+	Recipient  string   `json:"recipient"`
+	ChatID     int64    `json:"chat_id"`
+	MessageIDs []int64  `json:"message_ids"`
+	Type       string   `json:"type,omitempty"` // What the message was sent as, after any fallbacks
+	FileIDs    []string `json:"file_ids,omitempty"`
+	UploadURLs []string `json:"upload_urls,omitempty"`
+	Fallbacks  []string `json:"fallbacks,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+	Queued     bool     `json:"queued,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// writeJSONResults writes the results of sending a message to w, as a single line of JSON.
+func writeJSONResults(w io.Writer, results []tell.SendResult, err error, elapsed time.Duration) error {
+	out := jsonResults{
+		OK:        err == nil,
+		ElapsedMS: elapsed.Milliseconds(),
+		Results:   []jsonResult{},
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	for _, r := range results {
+		res := jsonResult{
+			Recipient:  r.Recipient.ID,
+			ChatID:     r.Recipient.ChatID,
+			MessageIDs: r.MessageIDs,
+			FileIDs:    r.FileIDs,
+			UploadURLs: r.UploadURLs,
+			Fallbacks:  r.Fallbacks,
+			DurationMS: r.Duration.Milliseconds(),
+			Queued:     r.Queued,
+		}
+		if res.MessageIDs == nil {
+			res.MessageIDs = []int64{}
+		}
+		if r.Err != nil {
+			res.Error = r.Err.Error()
+		} else {
+			res.Type = r.Type.String()
+		}
+		out.Results = append(out.Results, res)
+	}
+
+	return json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mikolysz/tell"
+)
+
+// progressPattern finds percentages like "45%" or "12.5 %" in a line of output.
+var progressPattern = regexp.MustCompile(`(\d{1,3}(?:\.\d+)?) ?%`)
+
+// progressBarWidth is how many blocks make up a full progress bar.
+const progressBarWidth = 20
+
+// live reads r line by line, keeping a single message up to date with the latest lines and the progress they report.
+// When r ends, or tell is interrupted, the message gets its final text and a status is sent as a reply to it.
+// It returns true if r was read to the end and the final status was sent.
+func live(client *tell.Client, env *environment, r io.Reader) bool {
+	// Interrupting tell ends the input, but the final status should still be sent.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(interrupts)
+	ctx := context.Background()
+
+	// Input is read in the background, so that a slow edit never blocks the program writing it.
+	out := &liveOutput{tail: &tailWriter{limit: env.tailLines}, changed: make(chan struct{}, 1)}
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(out, r)
+		done <- err
+	}()
+
+	msg := client.NewLive(env.msg, env.to...)
+	ticker := time.NewTicker(msg.Interval)
+	defer ticker.Stop()
+
+	var lastErr string
+	update := func() {
+		// Held back updates are retried on the next tick, so the same error is only reported once.
+		if err := msg.Update(ctx, out.text(env)); err != nil && err.Error() != lastErr {
+			fmt.Fprintln(os.Stderr, "Could not update live message:", err)
+			lastErr = err.Error()
+		}
+	}
+
+	start := time.Now()
+	var status string
+	ok := true
+loop:
+	for {
+		select {
+		case <-out.changed:
+			update()
+		case <-ticker.C:
+			update()
+		case err := <-done:
+			elapsed := time.Since(start).Round(time.Second)
+			if err != nil {
+				status = fmt.Sprintf("Failed after %s: %s", elapsed, err)
+				ok = false
+			} else {
+				status = fmt.Sprintf("Finished after %s", elapsed)
+			}
+			break loop
+		case <-interrupts:
+			status = fmt.Sprintf("Interrupted after %s", time.Since(start).Round(time.Second))
+			ok = false
+			break loop
+		}
+	}
+
+	if !env.code {
+		status = tell.EscapeText(status, env.format)
+	}
+	if err := msg.Finish(ctx, out.text(env), status); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not send final status:", err)
+		return false
+	}
+	return ok
+}
+
+// liveOutput remembers the input of a live message, and signals changed whenever more of it is written.
+// Progress is looked for as the input comes in, as progress bars often redraw their line before it's shown.
+type liveOutput struct {
+	tail    *tailWriter
+	changed chan struct{}
+
+	mu      sync.Mutex
+	segment []byte // The input since the last line ending or carriage return
+	percent string // The latest percentage seen, once there's been one
+}
+
+func (o *liveOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	for _, c := range p {
+		if c == '\n' || c == '\r' {
+			o.findProgress()
+			o.segment = o.segment[:0]
+		} else if len(o.segment) < maxTailLine {
+			o.segment = append(o.segment, c)
+		}
+	}
+	o.mu.Unlock()
+
+	n, err := o.tail.Write(p)
+	select {
+	case o.changed <- struct{}{}:
+	default: // There's already an update waiting
+	}
+	return n, err
+}
+
+// findProgress remembers the last percentage in the current segment, if it has one. It's called with o.mu held.
+func (o *liveOutput) findProgress() {
+	if m := progressPattern.FindAllSubmatch(o.segment, -1); m != nil {
+		o.percent = string(m[len(m)-1][1])
+	}
+}
+
+// text returns the message to show for the input so far: the text given on the command line,
+// a progress bar if the input reports its progress, and the latest lines.
+func (o *liveOutput) text(env *environment) string {
+	o.mu.Lock()
+	o.findProgress() // An unfinished line may already say how far along things are
+	percent := o.percent
+	o.mu.Unlock()
+
+	var parts []string
+	if percent != "" {
+		parts = append(parts, progressBar(percent))
+	}
+	parts = append(parts, o.tail.Lines()...)
+	body := strings.Join(parts, "\n")
+	if !env.code {
+		// With --code, the whole message is escaped when it's wrapped in a code block.
+		body = tell.EscapeText(body, env.format)
+	}
+
+	if header := env.msg.Text(); header != "" {
+		return header + "\n\n" + body
+	}
+	return body
+}
+
+// progressBar draws a bar filled up to the given percentage, followed by the percentage itself.
+func progressBar(percent string) string {
+	p, _ := strconv.ParseFloat(percent, 64)
+	if p > 100 {
+		p = 100
+	}
+	filled := int(p/100*progressBarWidth + 0.5)
+	return strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled) + " " + percent + "%"
+}
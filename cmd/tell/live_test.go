@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mikolysz/tell"
+	"github.com/mikolysz/tell/internal/fakebot"
+)
+
+func TestLive(t *testing.T) {
+	api := fakebot.New(t)
+	client, err := tell.NewClient(&tell.Config{BotToken: fakebot.Token, APIURL: api.URL, Recipients: map[string]int64{"alice": 1}})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	env, err := initEnvironment([]string{"--live", "--tail", "2", "Nightly", "import"}, tell.SizeLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !live(client, env, strings.NewReader("loading users\n10%\r45%\rrows imported\nrows checked\n")) {
+		t.Fatalf("live message failed")
+	}
+
+	// The input may all be read before the first update, in which case there's nothing left to edit.
+	sent := api.Calls("sendMessage")
+	final := sent[0].Params["text"]
+	if edits := api.Calls("editMessageText"); len(edits) > 0 {
+		final = edits[len(edits)-1].Params["text"]
+	}
+	want := "Nightly import\n\n█████████░░░░░░░░░░░ 45%\nrows imported\nrows checked"
+	if final != want {
+		t.Errorf("wrong final text, expected %q, got %q", want, final)
+	}
+
+	if len(sent) != 2 || !strings.HasPrefix(sent[1].Params["text"], "Finished after") || sent[1].Params["reply_to_message_id"] != "1" {
+		t.Errorf("expected the final status to reply to the live message, got %v", sent)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	for percent, want := range map[string]string{
+		"0":    "░░░░░░░░░░░░░░░░░░░░ 0%",
+		"12.5": "███░░░░░░░░░░░░░░░░░ 12.5%",
+		"100":  "████████████████████ 100%",
+		"250":  "████████████████████ 250%",
+	} {
+		if got := progressBar(percent); got != want {
+			t.Errorf("%s: expected %q, got %q", percent, want, got)
+		}
+	}
+}
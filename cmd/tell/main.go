@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
+
+	"github.com/mikolysz/tell"
+)
+
+func main() {
+	configPath, err := tell.DefaultConfigPath()
+	must("Could not get default config path:", err)
+
+	cfg, err := tell.LoadConfig(configPath)
+
+	// Exit the program if loading the config has failed, but not if it's just not there
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		must("Could not load config:", err)
+	}
+
+	// Set up the environment. The config is needed first, as the size limits depend on the Bot API server.
+	env, envErr := initEnvironment(os.Args[1:], cfg.SizeLimits())
+	must("", envErr)
+
+	if errors.Is(err, os.ErrNotExist) && env.token == "" {
+		var errorMessage = "no bot token found.\n\nTo obtain one, create a bot by sending /newbot to @BotFather (https://t.me/botfather).\n\nSet your token with 'tell -t <token>"
+		fmt.Fprintln(os.Stderr, errorMessage)
+		os.Exit(1)
+	}
+
+	if cfg != nil && len(cfg.Recipients) == 0 && !env.authorizeNewUser && env.token == "" {
+		fmt.Fprintln(os.Stderr, "No authorized user found. Please authorize a user with 'tell -a'")
+		os.Exit(1)
+	}
+
+	// Set the token or authorize the user if requested
+	if env.token != "" {
+		// It's possible that the config doesn't exist yet, so we need to create it
+		if cfg == nil {
+			cfg = &tell.Config{}
+		}
+
+		cfg.BotToken = env.token
+		must("Could not save config:", cfg.Save(configPath))
+		fmt.Fprintf(os.Stderr, "Token has been set!\n\nNow, authorize your Telegram account to receive notifications with 'tell -a'")
+		os.Exit(0)
+	}
+
+	if env.authorizeNewUser {
+		client, err := newClient(cfg, env)
+		must("Could not create bot instance:", err)
+		unlock, err := lockUpdates()
+		must("Could not authorize user:", err)
+		chat, err := authorize(client.Bot())
+		unlock()
+		must("Could not authorize user:", err)
+		id := cfg.AddRecipient(env.recipientID, chat.Username, chat.Id)
+		must("Could not save config:", cfg.Save(configPath))
+		fmt.Fprintf(os.Stderr, "\nUser authorized with ID %s.\n", id)
+		os.Exit(0)
+	}
+
+	_, err = cfg.LookupRecipients(env.to)
+	must("Invalid recipients:", err)
+
+	if env.explain {
+		must("Could not explain routing:", explain(os.Stdout, cfg, env))
+		os.Exit(0)
+	}
+
+	if env.encrypt && cfg.EncryptionKey == "" {
+		cfg.EncryptionKey, err = tell.NewEncryptionKey()
+		must("Could not generate encryption key:", err)
+		must("Could not save config:", cfg.Save(configPath))
+		fmt.Fprintf(os.Stderr, "A new encryption key has been saved in %s. Copy it to the computers that should decrypt your files.\n", configPath)
+	}
+
+	outboxPath, err := tell.DefaultOutboxPath()
+	must("Could not get default outbox path:", err)
+	outbox := tell.NewOutbox(outboxPath)
+	outbox.MaxAge = env.maxAge
+
+	if env.queueStatus {
+		must("Could not read outbox:", printQueueStatus(os.Stdout, outbox))
+		os.Exit(0)
+	}
+
+	client, err := newClient(cfg, env)
+	must("Could not create bot instance:", err)
+	if !env.noQueue {
+		client.Outbox = outbox
+	}
+
+	if env.flush {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		report, err := client.Flush(ctx, outbox)
+		stop()
+		fmt.Fprintf(os.Stderr, "Outbox: %s\n", formatFlushReport(report))
+		must("Could not deliver all queued messages:", err)
+		os.Exit(0)
+	}
+
+	if env.daemon {
+		unlock, err := lockUpdates()
+		must("Could not start daemon:", err)
+		defer unlock()
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		must("Daemon failed:", daemon(ctx, client, cfg, env.scriptTimeout))
+		return
+	}
+
+	if env.receive {
+		unlock, err := lockUpdates()
+		must("Could not receive message:", err)
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		err = receive(ctx, client, cfg)
+		stop()
+		unlock()
+		// The offset is saved even if receiving failed, so that skipped updates aren't fetched again.
+		saveErr := cfg.Save(configPath)
+		must("Could not receive message:", err)
+		must("Could not save config:", saveErr)
+		os.Exit(0)
+	}
+
+	if env.deleteID != 0 {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		err := client.Delete(ctx, env.recipient(), env.deleteID)
+		stop()
+		must("Could not delete message:", err)
+		os.Exit(0)
+	}
+
+	if env.live {
+		must("Invalid routing rules:", applySendOptions(cfg, env))
+		if !live(client, env, os.Stdin) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if env.command != nil {
+		text, exitCode := runCommand(env.command, env.tailLines)
+		if !env.code {
+			// With --code, the whole message is escaped when it's wrapped in a code block.
+			text = tell.EscapeText(text, env.format)
+		}
+
+		if env.msg.Text() != "" {
+			text = env.msg.Text() + "\n\n" + text
+		}
+		env.msg.WithText(text)
+
+		// The command's exit code is passed on even if the notification fails, scripts depend on it more.
+		// send reports its failures on stderr.
+		if err := applySendOptions(cfg, env); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid routing rules:", err)
+		} else {
+			send(client, env)
+		}
+		os.Exit(exitCode)
+	}
+
+	must("Invalid routing rules:", applySendOptions(cfg, env))
+	if !send(client, env) {
+		os.Exit(1)
+	}
+}
+
+// applySendOptions gives the message its options: the defaults from the config, changed by the routing rule that
+// applies to it, then by the flags. It's called right before sending, once the text the rules match is final.
+func applySendOptions(cfg *tell.Config, env *environment) error {
+	opts, _, err := routeOptions(cfg, env)
+	if err != nil {
+		return err
+	}
+	env.msg.WithOptions(opts)
+	return nil
+}
+
+// routeOptions returns the options the message is sent with, and the index of the routing rule that applies to it,
+// or -1 if none does.
+func routeOptions(cfg *tell.Config, env *environment) (tell.SendOptions, int, error) {
+	index, err := cfg.Route(env.msg)
+	if err != nil {
+		return tell.SendOptions{}, -1, err
+	}
+	opts := cfg.Defaults
+	if index >= 0 {
+		opts = cfg.Routes[index].Options(opts)
+	}
+	return env.sendOptions(opts), index, nil
+}
+
+// newClient creates a client for the bot in the config, applying the overrides from the environment and command line.
+// The overrides are only used for this run, they're never saved in the config.
+func newClient(cfg *tell.Config, env *environment) (*tell.Client, error) {
+	clientCfg := *cfg
+	if apiURL := os.Getenv(apiURLVariable); apiURL != "" {
+		clientCfg.APIURL = apiURL
+	}
+	if env.uploadBackend != "" {
+		clientCfg.Upload.Backend = env.uploadBackend
+	}
+
+	client, err := tell.NewClient(&clientCfg)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = env.timeout
+	client.OnPartDownload = func(part, parts int) {
+		fmt.Fprintf(os.Stderr, "Downloading part %d of %d\n", part, parts)
+	}
+	client.Retries = env.retries
+	return client, nil
+}
+
+// send sends a message to the recipients with the given IDs, reporting any failures on stderr.
+// When there's more than one recipient, successful sends are reported too.
+// With --verbose, files that were sent as documents after Telegram rejected them are reported as well,
+// and with --json, the results are written to stdout. With --pin, the messages are pinned once they're sent,
+// and with --edit, the message is edited instead.
+// It returns true if sending to all recipients succeeded.
+// Interrupting tell stops sending, and removes any temporary files.
+func send(client *tell.Client, env *environment) bool {
+	// Only set up now, a command run before sending gets interrupted with tell, and its output should still be sent.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if env.editID != 0 {
+		return edit(ctx, client, env)
+	}
+
+	start := time.Now()
+	results, err := client.Send(ctx, env.msg, env.to...)
+	if env.json {
+		if jsonErr := writeJSONResults(os.Stdout, results, err, time.Since(start)); jsonErr != nil {
+			fmt.Fprintln(os.Stderr, "Could not write results:", jsonErr)
+		}
+	}
+	if results == nil {
+		fmt.Fprintln(os.Stderr, "Could not send message:", err)
+		return false
+	}
+
+	if env.verbose {
+		// Later recipients get the documents straight away, but the reasons are kept, so each is only shown once.
+		reported := make(map[string]bool)
+		for _, r := range results {
+			for _, f := range r.Fallbacks {
+				if !reported[f] {
+					fmt.Fprintln(os.Stderr, f)
+					reported[f] = true
+				}
+			}
+		}
+	}
+
+	for _, r := range results {
+		switch {
+		case r.Queued && len(results) == 1:
+			fmt.Fprintln(os.Stderr, "Could not send message, it was queued and will be sent by 'tell --flush' or the daemon:", r.Err)
+		case r.Queued:
+			fmt.Fprintf(os.Stderr, "%s: could not send message, it was queued: %s\n", r.Recipient.ID, r.Err)
+		case r.Err != nil && len(results) == 1:
+			fmt.Fprintln(os.Stderr, "Could not send message:", r.Err)
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "%s: could not send message: %s\n", r.Recipient.ID, r.Err)
+		case len(results) > 1:
+			fmt.Fprintf(os.Stderr, "%s: sent\n", r.Recipient.ID)
+		}
+	}
+
+	ok := err == nil
+	if env.pin {
+		for _, r := range results {
+			if r.Err != nil || len(r.MessageIDs) == 0 {
+				continue
+			}
+			// Text split into several messages is pinned at its start.
+			if err := client.Pin(ctx, r.Recipient.ID, r.MessageIDs[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: could not pin message: %s\n", r.Recipient.ID, err)
+				ok = false
+			}
+		}
+	}
+	return ok
+}
+
+// edit replaces the text of the message given with --edit, then pins or unpins it if requested.
+// It returns true if all of that succeeded.
+func edit(ctx context.Context, client *tell.Client, env *environment) bool {
+	to := env.recipient()
+	if err := client.Edit(ctx, to, env.editID, env.msg); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not edit message:", err)
+		return false
+	}
+
+	var err error
+	switch {
+	case env.pin:
+		err = client.Pin(ctx, to, env.editID)
+	case env.unpin:
+		err = client.Unpin(ctx, to, env.editID)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not change pinned message:", err)
+		return false
+	}
+	return true
+}
+
+// apiURLVariable is the environment variable that points tell at a different Bot API server, like the fake one used in tests.
+// It takes precedence over api_url in the config.
+const apiURLVariable = "TELL_API_URL"
+
+func authorize(bot *gotgbot.Bot) (chat *gotgbot.Chat, err error) {
+	// Generate a random 6-digit code
+	code := rand.Intn(999999-100000) + 100000
+	fmt.Fprintf(os.Stderr, "Please send the following code to @%s: %d", bot.Username, code)
+
+	chatChan := make(chan *gotgbot.Chat)
+
+	// Prepare to receive messages:
+	updater := ext.NewUpdater(nil)
+	handler := handlers.NewMessage(message.Contains(fmt.Sprintf("%d", code)), func(b *gotgbot.Bot, ctx *ext.Context) error {
+		chatChan <- ctx.EffectiveChat
+		return nil
+	})
+
+	updater.Dispatcher.AddHandler(handler)
+
+	go updater.StartPolling(bot, nil)
+	chat = <-chatChan
+	updater.Stop()
+	return chat, nil
+}
+
+func must(message string, err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, message, err)
+		os.Exit(1)
+	}
+}
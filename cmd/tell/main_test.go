@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"github.com/mikolysz/tell"
+	"github.com/mikolysz/tell/internal/fakebot"
+)
+
+// runMainVariable makes the test binary run tell instead of the tests, so that the whole CLI can be tested.
+const runMainVariable = "TELL_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainVariable) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTell runs tell with the given arguments against a fake Bot API server, with home as the home directory.
+// It returns the output and whether tell succeeded.
+func runTell(t *testing.T, api *fakebot.Server, home string, args ...string) (stdout, stderr string, ok bool) {
+	t.Helper()
+
+	cmd := tellCommand(api, home, args...)
+	var out, errOut strings.Builder
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		t.Fatalf("failed to run tell: %s", err)
+	}
+	return out.String(), errOut.String(), err == nil
+}
+
+// tellCommand returns a command that runs tell like runTell does.
+func tellCommand(api *fakebot.Server, home string, args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = home
+	cmd.Env = append(os.Environ(), runMainVariable+"=1", apiURLVariable+"="+api.URL, "HOME="+home)
+	return cmd
+}
+
+// writeTestConfig creates a config with a bot token and the given recipients in home.
+func writeTestConfig(t *testing.T, home string, recipients map[string]int64) {
+	t.Helper()
+
+	cfg := &tell.Config{BotToken: fakebot.Token, Recipients: recipients}
+	if err := cfg.Save(filepath.Join(home, ".tell.json")); err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+}
+
+func TestCLISend(t *testing.T) {
+	api := fakebot.New(t)
+	home := t.TempDir()
+	writeTestConfig(t, home, map[string]int64{"alice": 1, "bob": 2})
+
+	if _, stderr, ok := runTell(t, api, home, "--to", "bob", "Deploy", "finished"); !ok {
+		t.Fatalf("tell failed: %s", stderr)
+	}
+
+	calls := api.Calls("sendMessage")
+	if len(calls) != 1 || calls[0].Params["chat_id"] != "2" || calls[0].Params["text"] != "Deploy finished" {
+		t.Errorf("expected the message to be sent to bob only, got %v", calls)
+	}
+
+	api.Fail("sendMessage", 400, "Bad Request: chat not found")
+	if _, stderr, ok := runTell(t, api, home, "--to", "alice", "Hello"); ok || !strings.Contains(stderr, "chat not found") {
+		t.Errorf("expected tell to fail with the API's error, got %q", stderr)
+	}
+}
+
+func TestCLICommandExitCode(t *testing.T) {
+	api := fakebot.New(t)
+	home := t.TempDir()
+	writeTestConfig(t, home, map[string]int64{"alice": 1})
+
+	// The command's exit code matters more to scripts than the notification.
+	api.Fail("sendMessage", 400, "Bad Request: chat not found")
+	cmd := tellCommand(api, home, "--", "sh", "-c", "exit 3")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("expected the command's exit code 3, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "chat not found") {
+		t.Errorf("expected the failed notification to be reported, got %q", stderr.String())
+	}
+}
+
+func TestCLIJSON(t *testing.T) {
+	api := fakebot.New(t)
+	home := t.TempDir()
+	writeTestConfig(t, home, map[string]int64{"alice": 1, "bob": 2})
+
+	file, err := filepath.Abs("testdata/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, ok := runTell(t, api, home, "--json", "-f", file, "Build", "log")
+	if !ok {
+		t.Fatalf("tell failed: %s", stderr)
+	}
+
+	var out jsonResults
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("invalid JSON output %q: %s", stdout, err)
+	}
+	if !out.OK || len(out.Results) != 2 {
+		t.Fatalf("expected results for both recipients, got %+v", out)
+	}
+
+	bob := out.Results[1]
+	if bob.Recipient != "bob" || bob.ChatID != 2 || bob.Type != "document" || len(bob.MessageIDs) != 1 || len(bob.FileIDs) != 1 {
+		t.Errorf("wrong result for bob, got %+v", bob)
+	}
+	if docs := api.Calls("sendDocument"); docs[1].Params["chat_id"] != "2" {
+		t.Errorf("expected the second document to be sent to bob, got %v", docs)
+	}
+
+	api.Fail("sendMessage", 400, "Bad Request: chat not found")
+	stdout, _, ok = runTell(t, api, home, "--json", "--to", "alice", "Hello")
+	if err := json.Unmarshal([]byte(stdout), &out); ok || err != nil || out.OK || !strings.Contains(out.Results[0].Error, "chat not found") {
+		t.Errorf("expected the failure to be in the JSON output, got %q", stdout)
+	}
+}
+
+func TestCLIEdit(t *testing.T) {
+	api := fakebot.New(t)
+	home := t.TempDir()
+	writeTestConfig(t, home, map[string]int64{"alice": 1, "bob": 2})
+
+	if _, stderr, ok := runTell(t, api, home, "--to", "bob", "--pin", "Deploy", "started"); !ok {
+		t.Fatalf("tell failed: %s", stderr)
+	}
+	sent := api.Calls("sendMessage")
+	pins := api.Calls("pinChatMessage")
+	if len(sent) != 1 || len(pins) != 1 || pins[0].Params["chat_id"] != "2" || pins[0].Params["message_id"] != "1" {
+		t.Fatalf("expected the message to be pinned after it was sent, got %v", pins)
+	}
+
+	if _, stderr, ok := runTell(t, api, home, "--to", "bob", "--edit", "1", "--unpin", "Deploy", "finished"); !ok {
+		t.Fatalf("tell failed: %s", stderr)
+	}
+	edits := api.Calls("editMessageText")
+	if len(edits) != 1 || edits[0].Params["message_id"] != "1" || edits[0].Params["text"] != "Deploy finished" {
+		t.Errorf("expected the message to be edited, got %v", edits)
+	}
+	if unpins := api.Calls("unpinChatMessage"); len(unpins) != 1 {
+		t.Errorf("expected the message to be unpinned, got %v", unpins)
+	}
+
+	if _, stderr, ok := runTell(t, api, home, "--to", "bob", "--reply-to", "1", "Rollback", "needed"); !ok {
+		t.Fatalf("tell failed: %s", stderr)
+	}
+	if sent := api.Calls("sendMessage"); len(sent) != 2 || sent[1].Params["reply_to_message_id"] != "1" {
+		t.Errorf("expected a reply to the first message, got %v", sent)
+	}
+
+	if _, stderr, ok := runTell(t, api, home, "--to", "bob", "--delete", "1"); !ok {
+		t.Fatalf("tell failed: %s", stderr)
+	}
+	if deletes := api.Calls("deleteMessage"); len(deletes) != 1 || deletes[0].Params["message_id"] != "1" {
+		t.Errorf("expected the message to be deleted, got %v", deletes)
+	}
+
+	// With two users and no --to, there's no telling whose chat the ID belongs to.
+	if _, stderr, ok := runTell(t, api, home, "--delete", "1"); ok || !strings.Contains(stderr, "single recipient") {
+		t.Errorf("expected deleting without choosing a recipient to fail, got %q", stderr)
+	}
+}
+
+func TestCLIReceive(t *testing.T) {
+	api := fakebot.New(t)
+	home := t.TempDir()
+	writeTestConfig(t, home, map[string]int64{"alice": 1})
+
+	api.AddUpdate(gotgbot.Update{Message: &gotgbot.Message{Chat: gotgbot.Chat{Id: 1}, Text: "Ship it"}})
+
+	stdout, stderr, ok := runTell(t, api, home, "-r")
+	if !ok {
+		t.Fatalf("tell -r failed: %s", stderr)
+	}
+	if strings.TrimSpace(stdout) != "Ship it" {
+		t.Errorf("expected the message to be printed, got %q", stdout)
+	}
+
+	cfg, err := tell.LoadConfig(filepath.Join(home, ".tell.json"))
+	if err != nil {
+		t.Fatalf("failed to load config: %s", err)
+	}
+	if cfg.UpdateOffset != 2 {
+		t.Errorf("update offset not saved, expected 2, got %d", cfg.UpdateOffset)
+	}
+}
+
+func TestCLIReceiveWhileDaemonRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the daemon can only be detected where flock is available")
+	}
+	api := fakebot.New(t)
+	home := t.TempDir()
+	writeTestConfig(t, home, map[string]int64{"alice": 1})
+	api.AddUpdate(gotgbot.Update{Message: &gotgbot.Message{Chat: gotgbot.Chat{Id: 1}, Text: "Ship it"}})
+
+	// Stands in for a running daemon.
+	unlock, ok, err := tryLock(filepath.Join(home, ".tell_updates.lock"))
+	if err != nil || !ok {
+		t.Fatalf("failed to take the lock: %v", err)
+	}
+	defer unlock()
+
+	for _, flag := range []string{"-r", "-a"} {
+		_, stderr, ok := runTell(t, api, home, flag)
+		if ok || !strings.Contains(stderr, "already receiving") {
+			t.Errorf("expected tell %s to refuse to run, got %q", flag, stderr)
+		}
+	}
+	if calls := api.Calls("getUpdates"); len(calls) != 0 {
+		t.Errorf("expected no updates to be fetched, got %d calls", len(calls))
+	}
+}
+
+func TestCLIOutbox(t *testing.T) {
+	api := fakebot.New(t)
+	home := t.TempDir()
+	writeTestConfig(t, home, map[string]int64{"alice": 1})
+
+	api.Fail("sendMessage", 502, "Bad Gateway")
+	if _, stderr, ok := runTell(t, api, home, "--retries", "0", "Disk", "full"); !ok || !strings.Contains(stderr, "queued") {
+		t.Fatalf("expected the message to be queued, got %q", stderr)
+	}
+
+	stdout, stderr, ok := runTell(t, api, home, "--queue-status")
+	if !ok || !strings.Contains(stdout, "Disk full") || !strings.Contains(stdout, "to alice") {
+		t.Errorf("expected the queued message to be listed, got %q, %q", stdout, stderr)
+	}
+
+	if _, stderr, ok := runTell(t, api, home, "--flush"); !ok || !strings.Contains(stderr, "1 delivered") {
+		t.Errorf("expected the queued message to be delivered, got %q", stderr)
+	}
+	if calls := api.Calls("sendMessage"); len(calls) != 2 || calls[1].Params["text"] != "Disk full" {
+		t.Errorf("expected the message to be sent again, got %v", calls)
+	}
+
+	api.Fail("sendMessage", 502, "Bad Gateway")
+	if _, _, ok := runTell(t, api, home, "--retries", "0", "--no-queue", "Hello"); ok {
+		t.Errorf("tell succeeded even though the message was neither sent nor queued")
+	}
+	if stdout, _, _ := runTell(t, api, home, "--queue-status"); !strings.Contains(stdout, "No messages") {
+		t.Errorf("expected the outbox to be empty, got %q", stdout)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mikolysz/tell"
+)
+
+// printQueueStatus lists the messages waiting in the outbox, oldest first.
+func printQueueStatus(w io.Writer, outbox *tell.Outbox) error {
+	items, err := outbox.Items()
+	if err != nil {
+		return err
+	}
+
+	if len(items) == 0 {
+		fmt.Fprintln(w, "No messages are waiting to be sent.")
+		return nil
+	}
+
+	for _, item := range items {
+		to := "everyone"
+		if len(item.To) > 0 {
+			to = strings.Join(item.To, ", ")
+		}
+		fmt.Fprintf(w, "%s  queued %s  to %s\n", item.ID, item.Queued.Format(time.DateTime), to)
+
+		for _, f := range item.Files {
+			fmt.Fprintf(w, "    file: %s\n", f)
+		}
+		if item.Text != "" {
+			fmt.Fprintf(w, "    text: %s\n", summarize(item.Text, 60))
+		}
+		if item.Attempts > 0 {
+			fmt.Fprintf(w, "    %d failed attempts, last: %s\n", item.Attempts, item.LastError)
+		}
+	}
+	return nil
+}
+
+// summarize returns the first line of text, shortened to at most n characters.
+func summarize(text string, n int) string {
+	line, _, more := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > n {
+		line, more = string(runes[:n]), true
+	}
+	if more {
+		line += "…"
+	}
+	return line
+}
+
+// formatFlushReport describes what happened when the outbox was flushed.
+func formatFlushReport(r tell.FlushReport) string {
+	return fmt.Sprintf("%d delivered, %d failed, %d expired, %d still waiting", r.Delivered, r.Failed, r.Expired, r.Pending)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"github.com/mikolysz/tell"
+)
+
+// errUpdatesLocked is returned when another tell process is already fetching the bot's updates.
+var errUpdatesLocked = errors.New("another tell -d, tell -r or tell -a is already receiving the bot's messages, and Telegram gives each message to only one of them")
+
+// lockUpdates keeps other tell processes from fetching the bot's updates until the returned function is called.
+// Telegram hands each update out once, so a running daemon would take the messages meant for tell -r or tell -a.
+func lockUpdates() (unlock func(), err error) {
+	dirname, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	unlock, ok, err := tryLock(filepath.Join(dirname, ".tell_updates.lock"))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errUpdatesLocked
+	}
+	return unlock, nil
+}
+
+// receive fetches the newest message any authorized user has sent to the bot.
+// Text is printed to stdout, attached files are saved in the current directory.
+// cfg.UpdateOffset is advanced past all fetched updates once the message is handled, and up to the message if that
+// failed, so that it can be received again. The caller is responsible for saving it either way.
+func receive(ctx context.Context, client *tell.Client, cfg *tell.Config) error {
+	bot := client.Bot()
+	var newest *gotgbot.Update
+	offset := cfg.UpdateOffset
+	seen := offset - 1
+	defer func() { cfg.UpdateOffset = offset }()
+
+	// getUpdates returns at most 100 updates at a time, keep going until we've seen all of them.
+	// Telegram forgets the updates before the offset, so it never goes past the newest message until it's handled.
+	for {
+		updates, err := bot.GetUpdates(&gotgbot.GetUpdatesOpts{
+			Offset:         offset,
+			AllowedUpdates: []string{"message"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get updates: %w", err)
+		}
+
+		fetched := false
+		for i, u := range updates {
+			if u.UpdateId <= seen {
+				continue
+			}
+			fetched, seen = true, u.UpdateId
+
+			// Updates from users who aren't authorized are skipped, but still marked as seen.
+			if u.Message != nil && cfg.IsAuthorized(u.Message.Chat.Id) {
+				newest = &updates[i]
+			}
+		}
+		// Without new updates, there's nothing more to fetch. This also stops when 100 updates from strangers
+		// follow the newest message, as the ones after them can't be fetched without confirming it.
+		if !fetched {
+			break
+		}
+
+		offset = seen + 1
+		if newest != nil {
+			offset = newest.UpdateId
+		}
+	}
+
+	if newest == nil {
+		fmt.Fprintln(os.Stderr, "No new messages.")
+		return nil
+	}
+
+	msg := newest.Message
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption
+	}
+	if text != "" {
+		fmt.Println(text)
+	}
+
+	path, err := client.Download(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		fmt.Fprintf(os.Stderr, "File saved as %s\n", path)
+	}
+
+	offset = seen + 1
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"github.com/mikolysz/tell"
+	"github.com/mikolysz/tell/internal/fakebot"
+)
+
+func TestReceive(t *testing.T) {
+	api := fakebot.New(t)
+	client, err := tell.NewClient(&tell.Config{BotToken: fakebot.Token, APIURL: api.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+
+	// Files are saved in the current directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := &tell.Config{Recipients: map[string]int64{"alice": 42}}
+
+	api.AddFile("doc1", "report.txt", []byte("all good"))
+	api.AddUpdate(gotgbot.Update{Message: &gotgbot.Message{
+		Chat:     gotgbot.Chat{Id: 42},
+		Caption:  "Latest report",
+		Document: &gotgbot.Document{FileId: "doc1", FileUniqueId: "doc1", FileName: "report.txt"},
+	}})
+	// Messages from strangers are skipped.
+	api.AddUpdate(gotgbot.Update{Message: &gotgbot.Message{Chat: gotgbot.Chat{Id: 666}, Text: "hi"}})
+
+	if err := receive(context.Background(), client, cfg); err != nil {
+		t.Fatalf("failed to receive: %s", err)
+	}
+
+	data, err := os.ReadFile("report.txt")
+	if err != nil || string(data) != "all good" {
+		t.Errorf("file not downloaded, got %q, %v", data, err)
+	}
+	if cfg.UpdateOffset != 3 {
+		t.Errorf("update offset not advanced past all updates, expected 3, got %d", cfg.UpdateOffset)
+	}
+}
+
+func TestReceiveFailedDownload(t *testing.T) {
+	api := fakebot.New(t)
+	client, err := tell.NewClient(&tell.Config{BotToken: fakebot.Token, APIURL: api.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	client.Retries = 0
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := &tell.Config{Recipients: map[string]int64{"alice": 42}}
+
+	api.AddUpdate(gotgbot.Update{Message: &gotgbot.Message{Chat: gotgbot.Chat{Id: 42}, Text: "Old news"}})
+	api.AddFile("doc1", "report.txt", []byte("all good"))
+	api.AddUpdate(gotgbot.Update{Message: &gotgbot.Message{
+		Chat:     gotgbot.Chat{Id: 42},
+		Document: &gotgbot.Document{FileId: "doc1", FileUniqueId: "doc1", FileName: "report.txt"},
+	}})
+	api.AddUpdate(gotgbot.Update{Message: &gotgbot.Message{Chat: gotgbot.Chat{Id: 666}, Text: "hi"}})
+
+	api.Fail("getFile", 502, "Bad Gateway")
+	if err := receive(context.Background(), client, cfg); err == nil {
+		t.Fatalf("expected the download to fail")
+	}
+	if cfg.UpdateOffset != 2 {
+		t.Errorf("expected the offset to stop at the failed message, got %d", cfg.UpdateOffset)
+	}
+
+	// The message is still there the next time.
+	if err := receive(context.Background(), client, cfg); err != nil {
+		t.Fatalf("failed to receive: %s", err)
+	}
+	data, err := os.ReadFile("report.txt")
+	if err != nil || string(data) != "all good" {
+		t.Errorf("file not downloaded, got %q, %v", data, err)
+	}
+	if cfg.UpdateOffset != 4 {
+		t.Errorf("update offset not advanced past all updates, expected 4, got %d", cfg.UpdateOffset)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// runCommand runs the command given after "--", passing its standard streams through to the terminal.
+// It returns a notification describing how the command went, as well as the command's exit code.
+func runCommand(args []string, tailLines int) (text string, exitCode int) {
+	tail := &tailWriter{limit: tailLines}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, tail)
+	cmd.Stderr = io.MultiWriter(os.Stderr, tail)
+
+	// Ctrl-C is delivered to the whole process group, so the command receives it anyway.
+	// We stay alive to report what happened to it. SIGTERM is usually sent to tell alone, so it's passed on,
+	// or the command would be left running after tell is gone.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	start := time.Now()
+	err := cmd.Start()
+	if err == nil {
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case sig := <-signals:
+					if sig == syscall.SIGTERM {
+						cmd.Process.Signal(sig)
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+		err = cmd.Wait()
+		close(done)
+	}
+	duration := time.Since(start).Round(time.Millisecond)
+
+	var status string
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		status = "exit code 0"
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+		status = exitErr.ProcessState.String()
+		if exitCode < 0 {
+			// Killed by a signal, there's no exit code to pass on. Like the shell, report 128 plus the signal's number.
+			exitCode = 1
+			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+				exitCode = 128 + int(ws.Signal())
+			}
+		}
+	default:
+		// The command couldn't be started at all, use the same exit code as the shell would.
+		exitCode = 127
+		status = err.Error()
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown host"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Command: %s\n", strings.Join(args, " "))
+	fmt.Fprintf(&b, "Host: %s\n", hostname)
+	fmt.Fprintf(&b, "Status: %s\n", status)
+	fmt.Fprintf(&b, "Duration: %s\n", duration)
+
+	if lines := tail.Lines(); len(lines) > 0 {
+		fmt.Fprintf(&b, "\nLast %d lines of output:\n", len(lines))
+		b.WriteString(strings.Join(lines, "\n"))
+	}
+
+	return b.String(), exitCode
+}
+
+// maxTailLine is the longest line a tailWriter keeps, in bytes. Anything after it is dropped.
+const maxTailLine = 512
+
+// tailWriter remembers the last few lines written to it. A carriage return that isn't part of a line ending starts
+// the line again, like it does in a terminal, so that progress bars redrawing themselves keep only their latest state.
+// It is safe for concurrent use, so that stdout and stderr can share one.
+type tailWriter struct {
+	limit   int
+	mu      sync.Mutex
+	lines   []string
+	partial []byte // The current line, until a newline is written
+	cr      bool   // Whether the last byte written was a carriage return
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, c := range p {
+		if t.cr && c != '\n' {
+			t.partial = t.partial[:0]
+		}
+		t.cr = c == '\r'
+
+		switch {
+		case c == '\n':
+			t.push(string(t.partial))
+			t.partial = t.partial[:0]
+		case c != '\r' && len(t.partial) < maxTailLine:
+			t.partial = append(t.partial, c)
+		}
+	}
+	return len(p), nil
+}
+
+func (t *tailWriter) push(line string) {
+	if t.limit <= 0 {
+		return
+	}
+
+	if len(t.lines) == t.limit {
+		t.lines = append(t.lines[:0], t.lines[1:]...)
+	}
+	// A long line may have been cut in the middle of a character.
+	t.lines = append(t.lines, strings.ToValidUTF8(line, ""))
+}
+
+// Lines returns the remembered lines, including an unterminated last line.
+func (t *tailWriter) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := append([]string(nil), t.lines...)
+	if len(t.partial) > 0 && t.limit > 0 {
+		if len(lines) == t.limit {
+			lines = lines[1:]
+		}
+		lines = append(lines, strings.ToValidUTF8(string(t.partial), ""))
+	}
+	return lines
+}
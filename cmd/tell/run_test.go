@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunCommandSIGTERM(t *testing.T) {
+	// tell gets SIGTERM on its own, the command should get it too instead of running on.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Signal(syscall.SIGTERM)
+		}
+	}()
+
+	start := time.Now()
+	text, exitCode := runCommand([]string{"sleep", "10"}, 10)
+	if time.Since(start) > 5*time.Second || exitCode != 143 || !strings.Contains(text, "terminated") {
+		t.Errorf("expected the command to be terminated, got exit code %d after %s:\n%s", exitCode, time.Since(start), text)
+	}
+}
+
+func TestTailWriter(t *testing.T) {
+	tail := &tailWriter{limit: 2}
+	tail.Write([]byte("one\ntwo\nth"))
+	tail.Write([]byte("ree\nfour"))
+
+	want := []string{"three", "four"}
+	if got := tail.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong lines remembered, expected %q, got %q", want, got)
+	}
+
+	// Progress bars redraw their line, Windows line endings are still line endings.
+	tail = &tailWriter{limit: 3}
+	tail.Write([]byte("crlf\r"))
+	tail.Write([]byte("\n10%\r20%\r30%\r\n" + strings.Repeat("x", 2*maxTailLine)))
+
+	want = []string{"crlf", "30%", strings.Repeat("x", maxTailLine)}
+	if got := tail.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong lines remembered, expected %q, got %q", want, got)
+	}
+}
@@ -1,17 +1,42 @@
-package main
+package tell
 
 import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
 )
 
-type config struct {
-	BotToken string `json:"bot_token"`
-	ChatID   int64  `json:"chat_id"`
+// Config is tell's configuration, stored in ~/.tell.json.
+type Config struct {
+	BotToken       string           `json:"bot_token"`
+	Recipients     map[string]int64 `json:"recipients,omitempty"`       // Chat IDs of authorized users, by their tell IDs
+	ChatID         int64            `json:"chat_id,omitempty"`          // Only used by old configs, see migrate
+	UpdateOffset   int64            `json:"update_offset,omitempty"`    // The ID of the first update 'tell -r' hasn't seen yet
+	Upload         UploadConfig     `json:"upload,omitempty"`           // Where to upload files too large for Telegram
+	EncryptionKey  string           `json:"encryption_key,omitempty"`   // Base64-encoded key for 'tell -e', generated when first needed
+	APIURL         string           `json:"api_url,omitempty"`          // A self-hosted Bot API server to use instead of Telegram's
+	FileSizeLimit  int64            `json:"file_size_limit,omitempty"`  // Overrides the largest file size in bytes the server accepts
+	PhotoSizeLimit int64            `json:"photo_size_limit,omitempty"` // Overrides the largest photo size in bytes the server accepts
+	Defaults       SendOptions      `json:"defaults,omitempty"`         // The options messages are sent with, unless they have their own
+	Routes         []Route          `json:"routes,omitempty"`           // Rules deciding who gets which messages, the first that applies is used
+}
+
+// DefaultRecipient is the ID given to the user authorized by configs that only supported one user.
+const DefaultRecipient = "default"
+
+// Recipient is an authorized user that messages can be sent to.
+type Recipient struct {
+	ID     string
+	ChatID int64
 }
 
-func defaultConfigPath() (string, error) {
+// DefaultConfigPath returns the path of the config file, ~/.tell.json.
+func DefaultConfigPath() (string, error) {
 	dirname, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user home directory: %w", err)
@@ -20,30 +45,147 @@ func defaultConfigPath() (string, error) {
 	return dirname + "/.tell.json", nil
 }
 
-func loadConfig(path string) (*config, error) {
+// SizeLimits returns the limits of the configured Bot API server. The config may be nil.
+func (c *Config) SizeLimits() SizeLimits {
+	var limits SizeLimits
+	if c == nil {
+		return limits
+	}
+
+	if c.APIURL != "" && strings.TrimSuffix(c.APIURL, "/") != gotgbot.DefaultAPIURL {
+		limits.File = localFileSizeLimit
+	}
+	if c.FileSizeLimit > 0 {
+		limits.File = c.FileSizeLimit
+	}
+	if c.PhotoSizeLimit > 0 {
+		limits.Photo = c.PhotoSizeLimit
+	}
+	return limits
+}
+
+// LoadConfig reads the config file at path, upgrading it if it was written by an older version of tell.
+func LoadConfig(path string) (*Config, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open config file: %w", err)
 	}
 	defer file.Close()
 
-	var cfg config
+	var cfg Config
 	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to decode config file: %w", err)
 	}
 
+	cfg.migrate()
 	return &cfg, nil
 }
 
-func (c *config) save(path string) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to open or create config file: %w", err)
+// migrate upgrades configs written by older versions of tell.
+func (c *Config) migrate() {
+	// Older configs held exactly one chat ID.
+	if c.ChatID != 0 {
+		if c.Recipients == nil {
+			c.Recipients = make(map[string]int64)
+		}
+		if _, ok := c.Recipients[DefaultRecipient]; !ok {
+			c.Recipients[DefaultRecipient] = c.ChatID
+		}
+		c.ChatID = 0
+	}
+}
+
+// AddRecipient authorizes a chat under the given ID, replacing any chat that previously had that ID.
+// If id is empty, one is generated from the suggestion, or from a counter if the suggestion is taken.
+// It returns the ID that was used.
+func (c *Config) AddRecipient(id, suggestion string, chatID int64) string {
+	if c.Recipients == nil {
+		c.Recipients = make(map[string]int64)
+	}
+
+	if id == "" {
+		suggestion = strings.ToLower(suggestion)
+		if _, taken := c.Recipients[suggestion]; suggestion != "" && !taken {
+			id = suggestion
+		}
+	}
+
+	for i := len(c.Recipients) + 1; id == ""; i++ {
+		candidate := fmt.Sprintf("user%d", i)
+		if _, taken := c.Recipients[candidate]; !taken {
+			id = candidate
+		}
+	}
+
+	c.Recipients[id] = chatID
+	return id
+}
+
+// LookupRecipients looks up the recipients with the given IDs.
+// If no IDs are given, all authorized users are returned, sorted by ID.
+func (c *Config) LookupRecipients(ids []string) ([]Recipient, error) {
+	if len(ids) == 0 {
+		for id := range c.Recipients {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+
+	var res []Recipient
+	for _, id := range ids {
+		chatID, ok := c.Recipients[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown recipient %q", id)
+		}
+		res = append(res, Recipient{ID: id, ChatID: chatID})
 	}
-	defer f.Close()
+	return res, nil
+}
 
-	if err := json.NewEncoder(f).Encode(c); err != nil {
+// IsAuthorized reports whether messages from the given chat should be trusted.
+func (c *Config) IsAuthorized(chatID int64) bool {
+	for _, id := range c.Recipients {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the config to path, readable only by the current user. The file is replaced atomically,
+// so that a crash while saving never leaves a truncated config behind.
+func (c *Config) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
 		return fmt.Errorf("failed to encode config file: %w", err)
 	}
+
+	// A config linked from elsewhere, like a dotfiles repository, is replaced where it really is.
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	// The config holds the bot token and the encryption key, so only the user should be able to read it.
+	// Temporary files are created with mode 0600, and keep it when they replace the config, whatever its mode was.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tell_config_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(data, '\n'))
+	if syncErr := tmp.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,111 @@
+package tell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tell.json")
+	if err := os.WriteFile(path, []byte(`{"bot_token":"token","chat_id":42}`), 0o600); err != nil {
+		t.Fatalf("failed to write old config: %s", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load old config: %s", err)
+	}
+
+	if cfg.Recipients[DefaultRecipient] != 42 {
+		t.Errorf("chat ID not migrated, expected recipient %q with chat 42, got %v", DefaultRecipient, cfg.Recipients)
+	}
+
+	if cfg.ChatID != 0 {
+		t.Errorf("old chat ID kept after migration: %d", cfg.ChatID)
+	}
+}
+
+func TestConfigSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tell.json")
+	// Configs written by older versions of tell could be readable by everyone.
+	if err := os.WriteFile(path, []byte(`{"bot_token":"old"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.json")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&Config{BotToken: "token", EncryptionKey: "secret"}).Save(link); err != nil {
+		t.Fatalf("failed to save config: %s", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("expected the config to be readable only by the user, got mode %o", mode)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected the symlink to the config to be kept")
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil || cfg.BotToken != "token" || cfg.EncryptionKey != "secret" {
+		t.Errorf("config not saved, got %+v, %v", cfg, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("expected no temporary files to be left behind, got %v", entries)
+	}
+}
+
+func TestAddRecipient(t *testing.T) {
+	cfg := &Config{}
+
+	if id := cfg.AddRecipient("ops", "alice", 1); id != "ops" {
+		t.Errorf("explicit ID not used, expected ops, got %s", id)
+	}
+	if id := cfg.AddRecipient("", "Alice", 2); id != "alice" {
+		t.Errorf("username not used as ID, expected alice, got %s", id)
+	}
+	if id := cfg.AddRecipient("", "alice", 3); id != "user3" {
+		t.Errorf("taken username used as ID, expected user3, got %s", id)
+	}
+
+	recipients, err := cfg.LookupRecipients(nil)
+	if err != nil {
+		t.Fatalf("failed to list all recipients: %s", err)
+	}
+	if len(recipients) != 3 {
+		t.Errorf("existing recipients replaced, expected 3, got %v", recipients)
+	}
+
+	if _, err := cfg.LookupRecipients([]string{"ops", "bob"}); err == nil {
+		t.Errorf("lookup succeeded for unknown recipient")
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	var cfg *Config
+	if limits := cfg.SizeLimits(); limits.fileLimit() != fileSizeLimit || limits.photoLimit() != photoSizeLimit {
+		t.Errorf("wrong limits without a config: %+v", limits)
+	}
+
+	cfg = &Config{APIURL: "https://api.telegram.org/"}
+	if limits := cfg.SizeLimits(); limits.fileLimit() != fileSizeLimit {
+		t.Errorf("limits raised for Telegram's own server: %+v", limits)
+	}
+
+	cfg = &Config{APIURL: "http://localhost:8081"}
+	if limits := cfg.SizeLimits(); limits.fileLimit() != localFileSizeLimit {
+		t.Errorf("limits not raised for a local server, expected %d, got %d", localFileSizeLimit, limits.fileLimit())
+	}
+
+	cfg.FileSizeLimit, cfg.PhotoSizeLimit = 100, 10
+	if limits := cfg.SizeLimits(); limits.fileLimit() != 100 || limits.photoLimit() != 10 {
+		t.Errorf("configured limits not used: %+v", limits)
+	}
+}
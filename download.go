@@ -0,0 +1,165 @@
+package tell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// Download saves the file attached to a message in the current directory, and returns its path.
+// Files sent in parts are joined, and encrypted files decrypted with the client's key.
+// Existing files are never overwritten. If the message has no file attached, the path is empty.
+func (c *Client) Download(ctx context.Context, msg *gotgbot.Message) (string, error) {
+	fileID, name := attachment(msg)
+	if fileID == "" {
+		return "", nil
+	}
+
+	path, err := c.downloadFile(ctx, fileID, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+
+	manifest, err := readManifest(path)
+	if err != nil {
+		return "", err
+	}
+
+	if manifest != nil {
+		joined, err := c.joinParts(ctx, manifest)
+		if err != nil {
+			return "", fmt.Errorf("failed to join parts of %s: %w", manifest.Name, err)
+		}
+		os.Remove(path)
+		path = joined
+	}
+
+	return c.decryptIfNeeded(path)
+}
+
+// decryptIfNeeded decrypts a downloaded file if it was encrypted by tell.
+// It returns the path of the decrypted file, or the original path if it wasn't encrypted.
+func (c *Client) decryptIfNeeded(path string) (string, error) {
+	encrypted, err := isEncrypted(path)
+	if err != nil || !encrypted {
+		return path, err
+	}
+
+	if c.cfg.EncryptionKey == "" {
+		return "", fmt.Errorf("%s is encrypted, but no encryption key is configured", path)
+	}
+
+	key, err := decodeEncryptionKey(c.cfg.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	decrypted, err := decryptFile(path, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return decrypted, nil
+}
+
+// attachment returns the ID of the file attached to a message and a name to save it under.
+// If the message has no file attached, the ID is empty.
+func attachment(msg *gotgbot.Message) (fileID, name string) {
+	switch {
+	case msg.Document != nil:
+		return msg.Document.FileId, fileName(msg.Document.FileName, "document", msg.Document.FileUniqueId, "")
+	case msg.Audio != nil:
+		return msg.Audio.FileId, fileName(msg.Audio.FileName, "audio", msg.Audio.FileUniqueId, ".mp3")
+	case msg.Video != nil:
+		return msg.Video.FileId, fileName(msg.Video.FileName, "video", msg.Video.FileUniqueId, ".mp4")
+	case msg.Voice != nil:
+		return msg.Voice.FileId, fileName("", "voice", msg.Voice.FileUniqueId, ".ogg")
+	case len(msg.Photo) > 0:
+		// Telegram sends a photo in several sizes, the last one is the biggest.
+		photo := msg.Photo[len(msg.Photo)-1]
+		return photo.FileId, fileName("", "photo", photo.FileUniqueId, ".jpg")
+	}
+	return "", ""
+}
+
+// fileName returns the name the user gave to a file, or generates one if there is none.
+// Names coming from Telegram can't be trusted, so only the last path element is kept.
+func fileName(name, kind, uniqueID, extension string) string {
+	if name != "" {
+		name = filepath.Base(filepath.Clean("/" + name))
+		if name != "/" && name != "." {
+			return name
+		}
+	}
+	return kind + "_" + uniqueID + extension
+}
+
+// downloadFile saves a file from Telegram in the current directory.
+// Existing files are never overwritten.
+func (c *Client) downloadFile(ctx context.Context, fileID, name string) (string, error) {
+	body, err := c.openFile(ctx, fileID)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("file %s already exists", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return name, nil
+}
+
+// openFile starts downloading a file from Telegram.
+func (c *Client) openFile(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	var res json.RawMessage
+	err := c.retryPolicy().do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = c.bot.RequestWithContext(ctx, "getFile", map[string]string{"file_id": fileID}, nil, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	var file gotgbot.File
+	if err := json.Unmarshal(res, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode file info: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", file.GetURL(c.bot), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download file: server returned %s", resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,58 @@
+package tell
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+func TestFileName(t *testing.T) {
+	cases := []struct {
+		name, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../.bashrc", ".bashrc"},
+		{"/etc/passwd", "passwd"},
+		{"..", "photo_abc.jpg"},
+		{"", "photo_abc.jpg"},
+	}
+
+	for _, c := range cases {
+		if got := fileName(c.name, "photo", "abc", ".jpg"); got != c.want {
+			t.Errorf("wrong name for %q, expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+	client, api := newTestClient(t)
+
+	// Files are saved in the current directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	api.AddFile("doc1", "report.txt", []byte("all good"))
+	msg := &gotgbot.Message{Document: &gotgbot.Document{FileId: "doc1", FileUniqueId: "doc1", FileName: "../report.txt"}}
+
+	path, err := client.Download(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("failed to download file: %s", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if path != "report.txt" || string(data) != "all good" {
+		t.Errorf("file not saved as report.txt, got %q with %q, %v", path, data, err)
+	}
+
+	if _, err := client.Download(context.Background(), msg); err == nil {
+		t.Errorf("existing file overwritten")
+	}
+}
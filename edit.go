@@ -0,0 +1,139 @@
+package tell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// Edit replaces the text of a message sent earlier, or its caption if the message has a file. The file itself
+// can't be changed. Message IDs are only unique within a chat, so the message is looked for in a single recipient's
+// chat; an empty to means the only authorized user.
+func (c *Client) Edit(ctx context.Context, to string, messageID int64, msg *Message) error {
+	r, err := c.recipient(to)
+	if err != nil {
+		return err
+	}
+
+	msg = c.prepareMessage(msg)
+	if err := msg.Check(msg.limits); err != nil {
+		return err
+	}
+	if msg.typ != TextMessage {
+		return fmt.Errorf("only the text of a message can be edited, not its files")
+	}
+	if err := registerButtons(msg, []int64{r.ChatID}); err != nil {
+		return err
+	}
+
+	text, format := msg.render()
+	params := map[string]string{
+		"chat_id":    fmt.Sprint(r.ChatID),
+		"message_id": fmt.Sprint(messageID),
+	}
+	if format != PlainText {
+		params["parse_mode"] = string(format)
+	}
+	markup, err := msg.replyMarkup()
+	if err != nil {
+		return err
+	}
+	if markup != "" {
+		params["reply_markup"] = markup
+	}
+	msg.addOptions("editMessageText", params)
+
+	// Whether the message has a caption instead of text isn't known until Telegram says so.
+	err = c.editText(ctx, "editMessageText", "text", textLimit, text, params)
+	if telegramErrorContains(err, "there is no text in the message to edit") {
+		delete(params, "text")
+		delete(params, "link_preview_options") // Captions don't have link previews
+		err = c.editText(ctx, "editMessageCaption", "caption", captionLimit, text, params)
+	}
+	if telegramErrorContains(err, "message is not modified") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to edit message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// editText calls an edit method with the text in the given field. Edits can't be split, so text over the limit fails.
+func (c *Client) editText(ctx context.Context, method, field string, limit int, text string, params map[string]string) error {
+	if utf16Len(text) > limit {
+		return fmt.Errorf("the new %s is too long, the limit is %d characters", field, limit)
+	}
+	params[field] = text
+	_, err := c.retryPolicy().request(ctx, c.bot, method, params, nil)
+	return err
+}
+
+// Delete deletes a message sent earlier to a recipient. An empty to means the only authorized user.
+func (c *Client) Delete(ctx context.Context, to string, messageID int64) error {
+	if err := c.messageRequest(ctx, "deleteMessage", to, messageID, nil); err != nil {
+		return fmt.Errorf("failed to delete message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// Pin pins a message in a recipient's chat. An empty to means the only authorized user.
+func (c *Client) Pin(ctx context.Context, to string, messageID int64) error {
+	params := map[string]string{"disable_notification": "true"} // The message itself already notified them
+	if err := c.messageRequest(ctx, "pinChatMessage", to, messageID, params); err != nil {
+		return fmt.Errorf("failed to pin message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// Unpin unpins a message in a recipient's chat. An empty to means the only authorized user.
+func (c *Client) Unpin(ctx context.Context, to string, messageID int64) error {
+	if err := c.messageRequest(ctx, "unpinChatMessage", to, messageID, nil); err != nil {
+		return fmt.Errorf("failed to unpin message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// messageRequest calls a Bot API method that acts on a message in a recipient's chat.
+func (c *Client) messageRequest(ctx context.Context, method, to string, messageID int64, params map[string]string) error {
+	r, err := c.recipient(to)
+	if err != nil {
+		return err
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	params["chat_id"] = fmt.Sprint(r.ChatID)
+	params["message_id"] = fmt.Sprint(messageID)
+	_, err = c.retryPolicy().request(ctx, c.bot, method, params, nil)
+	return err
+}
+
+// recipient looks up a single recipient. An empty ID means the only authorized user, if there's exactly one.
+func (c *Client) recipient(id string) (Recipient, error) {
+	var ids []string
+	if id != "" {
+		ids = []string{id}
+	}
+	recipients, err := c.cfg.LookupRecipients(ids)
+	if err != nil {
+		return Recipient{}, err
+	}
+	switch len(recipients) {
+	case 0:
+		return Recipient{}, fmt.Errorf("no authorized users")
+	case 1:
+		return recipients[0], nil
+	default:
+		return Recipient{}, fmt.Errorf("there are %d authorized users, a single recipient must be chosen", len(recipients))
+	}
+}
+
+// telegramErrorContains reports whether err is an error from Telegram whose description contains s.
+func telegramErrorContains(err error, s string) bool {
+	var tgErr *gotgbot.TelegramError
+	return errors.As(err, &tgErr) && strings.Contains(strings.ToLower(tgErr.Description), s)
+}
@@ -0,0 +1,84 @@
+package tell
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEdit(t *testing.T) {
+	client, api := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.Edit(ctx, "bob", 7, NewMessage("Deploy *finished*").WithFormat(MarkdownV2)); err != nil {
+		t.Fatalf("failed to edit message: %s", err)
+	}
+	calls := api.Calls("editMessageText")
+	if len(calls) != 1 {
+		t.Fatalf("expected one edit, got %v", calls)
+	}
+	if p := calls[0].Params; p["chat_id"] != "2" || p["message_id"] != "7" || p["text"] != "Deploy *finished*" || p["parse_mode"] != "MarkdownV2" {
+		t.Errorf("wrong edit, got %v", p)
+	}
+
+	// Messages with a file have a caption instead of text.
+	api.Fail("editMessageText", 400, "Bad Request: there is no text in the message to edit")
+	if err := client.Edit(ctx, "bob", 8, NewMessage("New caption")); err != nil {
+		t.Fatalf("failed to edit caption: %s", err)
+	}
+	if calls := api.Calls("editMessageCaption"); len(calls) != 1 || calls[0].Params["caption"] != "New caption" || calls[0].Params["text"] != "" {
+		t.Errorf("expected the caption to be edited, got %v", calls)
+	}
+
+	api.Fail("editMessageText", 400, "Bad Request: message is not modified")
+	if err := client.Edit(ctx, "bob", 7, NewMessage("Deploy *finished*")); err != nil {
+		t.Errorf("editing a message without changing it failed: %s", err)
+	}
+
+	if err := client.Edit(ctx, "", 7, NewMessage("Hi")); err == nil {
+		t.Errorf("a message was edited without choosing one of several recipients")
+	}
+	if err := client.Edit(ctx, "bob", 7, NewMessage("").WithFile("testdata/foo")); err == nil {
+		t.Errorf("a file was accepted in an edit")
+	}
+	if err := client.Edit(ctx, "bob", 7, NewMessage(strings.Repeat("a", textLimit+1))); err == nil {
+		t.Errorf("text too long for a single message was accepted in an edit")
+	}
+}
+
+func TestDeleteAndPin(t *testing.T) {
+	client, api := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.Pin(ctx, "alice", 3); err != nil {
+		t.Fatalf("failed to pin message: %s", err)
+	}
+	if err := client.Unpin(ctx, "alice", 3); err != nil {
+		t.Fatalf("failed to unpin message: %s", err)
+	}
+	if err := client.Delete(ctx, "alice", 3); err != nil {
+		t.Fatalf("failed to delete message: %s", err)
+	}
+	for _, method := range []string{"pinChatMessage", "unpinChatMessage", "deleteMessage"} {
+		if calls := api.Calls(method); len(calls) != 1 || calls[0].Params["chat_id"] != "1" || calls[0].Params["message_id"] != "3" {
+			t.Errorf("%s: wrong calls %v", method, calls)
+		}
+	}
+
+	api.Fail("deleteMessage", 400, "Bad Request: message to delete not found")
+	if err := client.Delete(ctx, "alice", 4); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected deleting a missing message to fail, got %v", err)
+	}
+}
+
+func TestSendReply(t *testing.T) {
+	client, api := newTestClient(t)
+
+	if _, err := client.Send(context.Background(), NewMessage("Deploy finished").ReplyTo(5), "alice"); err != nil {
+		t.Fatalf("failed to send reply: %s", err)
+	}
+	calls := api.Calls("sendMessage")
+	if len(calls) != 1 || calls[0].Params["reply_to_message_id"] != "5" || calls[0].Params["allow_sending_without_reply"] != "true" {
+		t.Errorf("expected the message to be sent as a reply, got %v", calls)
+	}
+}
@@ -0,0 +1,263 @@
+package tell
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encrypted files start with a header made of encryptionMagic, the ID of the key and a random nonce prefix.
+// The data follows in chunks of encryptionChunkSize bytes, each sealed with AES-256-GCM.
+// Every chunk's nonce contains its number and whether it's the last one, so chunks can't be reordered or dropped.
+const (
+	encryptionMagic     = "TELLENC\x01"
+	encryptionChunkSize = 64 * 1024
+	encryptedSuffix     = ".enc"
+
+	keyIDSize       = 8
+	noncePrefixSize = 7
+	headerSize      = len(encryptionMagic) + keyIDSize + noncePrefixSize
+)
+
+var errWrongKey = errors.New("file was encrypted with a different key")
+
+// NewEncryptionKey generates a key for the config file.
+func NewEncryptionKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// decodeEncryptionKey decodes a key from the config file.
+func decodeEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key, expected 32 bytes in base64")
+	}
+	return key, nil
+}
+
+// keyID identifies a key without revealing it, so that decrypting with the wrong key fails with a clear error.
+func keyID(key []byte) []byte {
+	sum := sha256.Sum256(append([]byte("tell key id"), key...))
+	return sum[:keyIDSize]
+}
+
+// encryptFile encrypts a file into a temporary directory.
+// The returned remove function, if non-nil, deletes the encrypted file.
+func encryptFile(path string, key []byte) (encPath string, remove func(), err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	tmpdir, err := os.MkdirTemp("", "tell")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	remove = func() {
+		os.RemoveAll(tmpdir)
+	}
+
+	encPath = filepath.Join(tmpdir, filepath.Base(path)+encryptedSuffix)
+	dst, err := os.Create(encPath)
+	if err != nil {
+		return "", remove, fmt.Errorf("failed to create encrypted file: %w", err)
+	}
+
+	err = encrypt(dst, src, key)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", remove, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+
+	return encPath, remove, nil
+}
+
+func encrypt(w io.Writer, r io.Reader, key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, keyID(key)...)
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	header = append(header, noncePrefix...)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(r, encryptionChunkSize)
+	chunk := make([]byte, encryptionChunkSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		last, err := isLastChunk(br, err)
+		if err != nil {
+			return err
+		}
+
+		sealed := aead.Seal(nil, chunkNonce(noncePrefix, counter, last), chunk[:n], header)
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// isEncrypted reports whether a file starts with the header of a file encrypted by tell.
+func isEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(encryptionMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, nil
+	}
+	return string(magic) == encryptionMagic, nil
+}
+
+// decryptFile decrypts a file encrypted by tell into the current directory, and removes the encrypted file.
+// It returns the path of the decrypted file.
+func decryptFile(path string, key []byte) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	name := strings.TrimSuffix(path, encryptedSuffix)
+	if name == path {
+		name = path + ".decrypted"
+	}
+
+	// The file was encrypted for a reason, so other users of this machine can't read it either.
+	dst, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("file %s already exists", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+
+	err = decrypt(dst, src, key)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+
+	src.Close()
+	os.Remove(path)
+	return name, nil
+}
+
+func decrypt(w io.Writer, r io.Reader, key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return fmt.Errorf("not a file encrypted by tell")
+	}
+
+	if !bytes.Equal(header[len(encryptionMagic):len(encryptionMagic)+keyIDSize], keyID(key)) {
+		return errWrongKey
+	}
+	noncePrefix := header[len(encryptionMagic)+keyIDSize:]
+
+	br := bufio.NewReaderSize(r, encryptionChunkSize+aead.Overhead())
+	chunk := make([]byte, encryptionChunkSize+aead.Overhead())
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		last, err := isLastChunk(br, err)
+		if err != nil {
+			return err
+		}
+
+		plain, err := aead.Open(chunk[:0], chunkNonce(noncePrefix, counter, last), chunk[:n], header)
+		if err != nil {
+			return fmt.Errorf("file is corrupted or was tampered with")
+		}
+
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// isLastChunk reports whether the chunk that was just read is the last one, given the EOF error from reading it, if any.
+func isLastChunk(r *bufio.Reader, readErr error) (bool, error) {
+	if readErr != nil {
+		return true, nil
+	}
+
+	_, err := r.Peek(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
@@ -0,0 +1,82 @@
+package tell
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	for _, size := range []int{0, 1, encryptionChunkSize, encryptionChunkSize*2 + 7} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		var enc bytes.Buffer
+		if err := encrypt(&enc, bytes.NewReader(plain), key); err != nil {
+			t.Fatalf("failed to encrypt %d bytes: %s", size, err)
+		}
+
+		var dec bytes.Buffer
+		if err := decrypt(&dec, bytes.NewReader(enc.Bytes()), key); err != nil {
+			t.Fatalf("failed to decrypt %d bytes: %s", size, err)
+		}
+		if !bytes.Equal(dec.Bytes(), plain) {
+			t.Errorf("decrypted data differs from the original for %d bytes", size)
+		}
+
+		// Dropping the last chunk must be detected, even if it ends on a chunk boundary.
+		truncated := enc.Bytes()[:enc.Len()-1]
+		if size > encryptionChunkSize {
+			truncated = enc.Bytes()[:headerSize+encryptionChunkSize+16]
+		}
+		if err := decrypt(&bytes.Buffer{}, bytes.NewReader(truncated), key); err == nil {
+			t.Errorf("truncated file of %d bytes decrypted without error", size)
+		}
+	}
+
+	var enc bytes.Buffer
+	encrypt(&enc, bytes.NewReader([]byte("secret")), key)
+
+	otherKey := make([]byte, 32)
+	rand.Read(otherKey)
+	if err := decrypt(&bytes.Buffer{}, bytes.NewReader(enc.Bytes()), otherKey); !errors.Is(err, errWrongKey) {
+		t.Errorf("expected wrong key error, got %v", err)
+	}
+
+	tampered := bytes.Clone(enc.Bytes())
+	tampered[len(tampered)-1] ^= 1
+	if err := decrypt(&bytes.Buffer{}, bytes.NewReader(tampered), key); err == nil {
+		t.Errorf("tampered file decrypted without error")
+	}
+}
+
+func TestDecryptFile(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	var enc bytes.Buffer
+	encrypt(&enc, bytes.NewReader([]byte("secret")), key)
+	path := filepath.Join(t.TempDir(), "notes.txt"+encryptedSuffix)
+	if err := os.WriteFile(path, enc.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := decryptFile(path, key)
+	if err != nil {
+		t.Fatalf("failed to decrypt file: %s", err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the decrypted file to be readable only by its owner, got mode %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,66 @@
+package tell
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// rejectedTypeErrors are parts of the errors Telegram gives when it won't accept a file as the type it was sent as,
+// but would accept it as a document. Any other error is fatal, sending the file as a document wouldn't help.
+var rejectedTypeErrors = []string{
+	"PHOTO_INVALID_DIMENSIONS",
+	"PHOTO_INVALID",
+	"PHOTO_EXT_INVALID",
+	"PHOTO_SAVE_FILE_INVALID",
+	"IMAGE_PROCESS_FAILED",
+	"VIDEO_CONTENT_TYPE_INVALID",
+	"VOICE_MESSAGES_FORBIDDEN",
+	"STICKER_",
+	"type of file mismatch",
+}
+
+// rejectedType reports whether err means Telegram didn't accept a file as the type it was sent as.
+func rejectedType(err error) bool {
+	var tgErr *gotgbot.TelegramError
+	if !errors.As(err, &tgErr) || tgErr.Code != 400 {
+		return false
+	}
+
+	description := strings.ToLower(tgErr.Description)
+	for _, e := range rejectedTypeErrors {
+		if strings.Contains(description, strings.ToLower(e)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fallBack switches a file Telegram rejected to being sent as a document, and reports whether it should be sent again.
+// Only automatically detected types are changed, a type chosen with WithType is kept.
+func (msg *Message) fallBack(err error) bool {
+	if !msg.detected || msg.filePath == "" || msg.typ == DocumentMessage || !rejectedType(err) {
+		return false
+	}
+
+	var tgErr *gotgbot.TelegramError
+	errors.As(err, &tgErr)
+	msg.fallback = fmt.Sprintf("%s was sent as a document, Telegram rejected it as a %s: %s", filepath.Base(msg.filePath), msg.typ, tgErr.Description)
+	msg.typ = DocumentMessage
+	return true
+}
+
+// fallbacks returns why files of the message were sent as documents after Telegram rejected them.
+func (msg *Message) fallbacks() []string {
+	var fallbacks []string
+	if msg.fallback != "" {
+		fallbacks = append(fallbacks, msg.fallback)
+	}
+	for _, part := range msg.parts {
+		fallbacks = append(fallbacks, part.fallbacks()...)
+	}
+	return fallbacks
+}
@@ -0,0 +1,44 @@
+package tell
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSendFallback(t *testing.T) {
+	client, api := newTestClient(t)
+	photo := pngFile(t, t.TempDir(), "chart.png", 640, 480)
+
+	api.Fail("sendPhoto", 400, "Bad Request: PHOTO_INVALID_DIMENSIONS")
+	results, err := client.Send(context.Background(), NewMessage("Chart").WithFile(photo), "alice", "bob")
+	if err != nil {
+		t.Fatalf("expected the photo to be sent as a document, got %s", err)
+	}
+	for _, r := range results {
+		if len(r.Fallbacks) != 1 || !strings.Contains(r.Fallbacks[0], "PHOTO_INVALID_DIMENSIONS") {
+			t.Errorf("%s: expected the fallback to be reported, got %q", r.Recipient.ID, r.Fallbacks)
+		}
+		if r.Type != DocumentMessage || len(r.FileIDs) != 1 {
+			t.Errorf("%s: expected a document to be reported, got %s with files %v", r.Recipient.ID, r.Type, r.FileIDs)
+		}
+	}
+
+	// Bob gets the document straight away.
+	if photos, docs := api.Calls("sendPhoto"), api.Calls("sendDocument"); len(photos) != 1 || len(docs) != 2 || docs[0].Params["caption"] != "Chart" {
+		t.Errorf("expected one rejected photo followed by two documents, got %d photos, %v", len(photos), docs)
+	}
+
+	api.Fail("sendPhoto", 400, "Bad Request: PHOTO_INVALID_DIMENSIONS")
+	if _, err := client.Send(context.Background(), NewMessage("").WithFile(photo).WithType(PhotoMessage), "alice"); err == nil {
+		t.Errorf("a photo sent with an explicit type fell back to a document")
+	}
+
+	api.Fail("sendPhoto", 400, "Bad Request: chat not found")
+	if _, err := client.Send(context.Background(), NewMessage("").WithFile(photo), "alice"); err == nil {
+		t.Errorf("a photo fell back to a document after a fatal error")
+	}
+	if docs := api.Calls("sendDocument"); len(docs) != 2 {
+		t.Errorf("expected no more documents to be sent, got %d", len(docs))
+	}
+}
@@ -0,0 +1,413 @@
+package tell
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Format is how Telegram formats the text of a message, one of its parse modes.
+// See https://core.telegram.org/bots/api#formatting-options
+type Format string
+
+const (
+	PlainText  Format = ""
+	MarkdownV2 Format = "MarkdownV2"
+	HTML       Format = "HTML"
+)
+
+// ParseFormat converts the name of a format, one of plain, markdown or html, to a Format.
+func ParseFormat(format string) (Format, error) {
+	switch format {
+	case "plain", "":
+		return PlainText, nil
+	case "markdown":
+		return MarkdownV2, nil
+	case "html":
+		return HTML, nil
+	default:
+		return "", fmt.Errorf("Invalid format: %s", format)
+	}
+}
+
+// markdownV2Escaper escapes every character that has a meaning in MarkdownV2 outside of code.
+var markdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// Inside pre and code entities, only backslashes and backticks have to be escaped.
+var markdownV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// EscapeText escapes text so that it shows up literally when sent with the given format.
+func EscapeText(text string, format Format) string {
+	switch format {
+	case MarkdownV2:
+		return markdownV2Escaper.Replace(text)
+	case HTML:
+		return html.EscapeString(text)
+	default:
+		return text
+	}
+}
+
+// codeBlock wraps text in a preformatted block, so that its alignment is kept.
+// Plain text can't contain code blocks, so HTML is used instead. It returns the text and the format to send it with.
+func codeBlock(text string, format Format) (string, Format) {
+	text = strings.TrimRight(text, "\n")
+	if format == MarkdownV2 {
+		return "```\n" + markdownV2CodeEscaper.Replace(text) + "\n```", MarkdownV2
+	}
+	return "<pre>" + html.EscapeString(text) + "</pre>", HTML
+}
+
+// Telegram's limits on the length of texts and captions, in UTF-16 code units.
+const (
+	textLimit    = 4096
+	captionLimit = 1024
+)
+
+// utf16Len returns the length of s the way Telegram counts it.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// htmlTag matches opening and closing HTML tags, capturing the slash and the tag name.
+var htmlTag = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)[^>]*>`)
+
+// textSplitter splits formatted text into chunks, closing any open entities at the end of a chunk
+// and opening them again at the start of the next one.
+type textSplitter struct {
+	format    Format
+	open      []string // Opening HTML tags or MarkdownV2 entities that haven't been closed yet
+	openNames []string // Names of the HTML tags in open, or the MarkdownV2 markup that closes each entity
+
+	// MarkdownV2 entities are found by reading ahead in the whole text, as a link's text comes before its URL.
+	text    string
+	scanned int // How much of text has been added to chunks
+	pos     int // Where to continue looking for entities, past scanned if markup was read ahead
+}
+
+// splitText splits text into chunks of at most firstLimit for the first chunk and limit for the rest.
+// Text is split on line boundaries where possible.
+func splitText(text string, format Format, firstLimit, limit int) []string {
+	s := &textSplitter{format: format, text: text}
+
+	var chunks []string
+	var cur strings.Builder
+	curLimit := firstLimit
+
+	finish := func() {
+		chunk := strings.TrimRight(cur.String(), "\n")
+		chunks = append(chunks, chunk+s.closing(chunk))
+		cur.Reset()
+		cur.WriteString(s.opening())
+		curLimit = limit
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for line != "" {
+			room := curLimit - utf16Len(cur.String()) - s.reserve(line)
+			if utf16Len(line) <= room {
+				cur.WriteString(line)
+				s.scan(line)
+				break
+			}
+
+			// The line doesn't fit, start a new chunk unless this one only holds reopened entities.
+			if strings.TrimSpace(cur.String()) != strings.TrimSpace(s.opening()) {
+				finish()
+				continue
+			}
+
+			// The line is too long for a chunk on its own, so it has to be cut.
+			room = curLimit - utf16Len(cur.String()) - s.reserve(line)
+			piece := s.cut(line, room)
+			for room > 0 {
+				// Entities opened and closed within the line may still be open at the end of the piece.
+				chunk := strings.TrimRight(cur.String()+piece, "\n")
+				over := utf16Len(chunk+s.after(piece).closing(chunk)) - curLimit
+				if over <= 0 {
+					break
+				}
+				room -= over
+				piece = s.cut(line, room)
+			}
+			cur.WriteString(piece)
+			s.scan(piece)
+			line = line[len(piece):]
+			finish()
+		}
+	}
+
+	if strings.TrimSpace(cur.String()) != strings.TrimSpace(s.opening()) || len(chunks) == 0 {
+		chunk := strings.TrimRight(cur.String(), "\n")
+		chunks = append(chunks, chunk+s.closing(chunk))
+	}
+	return chunks
+}
+
+// scan updates the open entities after text was added to a chunk.
+func (s *textSplitter) scan(text string) {
+	switch s.format {
+	case HTML:
+		for _, m := range htmlTag.FindAllStringSubmatch(text, -1) {
+			if m[1] == "" {
+				s.open = append(s.open, m[0])
+				s.openNames = append(s.openNames, strings.ToLower(m[2]))
+				continue
+			}
+
+			for i := len(s.openNames) - 1; i >= 0; i-- {
+				if s.openNames[i] == strings.ToLower(m[2]) {
+					s.open, s.openNames = s.open[:i], s.openNames[:i]
+					break
+				}
+			}
+		}
+	case MarkdownV2:
+		s.scanned += len(text)
+		s.scanMarkdown()
+	}
+}
+
+// scanMarkdown updates the open MarkdownV2 entities with the markup up to the end of the scanned text.
+func (s *textSplitter) scanMarkdown() {
+	for s.pos < s.scanned {
+		rest := s.text[s.pos:]
+		top := ""
+		if len(s.open) > 0 {
+			top = s.open[len(s.open)-1]
+		}
+
+		switch {
+		case rest[0] == '\\':
+			s.pos += 2
+		case strings.HasPrefix(top, "```"):
+			// Backticks inside code blocks are escaped, so the next unescaped ``` closes the block.
+			if strings.HasPrefix(rest, "```") {
+				s.pop(len(s.open) - 1)
+				s.pos += 3
+			} else {
+				s.pos++
+			}
+		case top == "`":
+			if rest[0] == '`' {
+				s.pop(len(s.open) - 1)
+			}
+			s.pos++
+		case strings.HasPrefix(rest, "```"):
+			opening := rest
+			if end := strings.IndexByte(opening, '\n'); end >= 0 {
+				opening = opening[:end+1]
+			}
+			s.open = append(s.open, opening)
+			s.openNames = append(s.openNames, "```")
+			s.pos += 3
+		case rest[0] == '[':
+			if url, ok := linkURL(rest); ok {
+				s.open = append(s.open, "[")
+				s.openNames = append(s.openNames, url)
+			}
+			s.pos++
+		case rest[0] == ']':
+			i := s.find("[")
+			if i < 0 {
+				s.pos++
+				break
+			}
+			s.pos += len(s.openNames[i]) // Skips the URL, which is added again whenever the link is closed
+			s.pop(i)
+		default:
+			marker := ""
+			for _, m := range []string{"__", "||", "_", "*", "~", "`"} {
+				if strings.HasPrefix(rest, m) {
+					marker = m
+					break
+				}
+			}
+			if marker == "" {
+				s.pos++
+				break
+			}
+			if i := s.find(marker); i >= 0 {
+				s.pop(i)
+			} else {
+				s.open = append(s.open, marker)
+				s.openNames = append(s.openNames, marker)
+			}
+			s.pos += len(marker)
+		}
+	}
+}
+
+// linkURL returns the markup after the text of a MarkdownV2 link starting at the beginning of text, like "](url)",
+// and false if text doesn't start with a link.
+func linkURL(text string) (string, bool) {
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case ']':
+			if !strings.HasPrefix(text[i:], "](") {
+				return "", false
+			}
+			for j := i + 2; j < len(text); j++ {
+				if text[j] == '\\' {
+					j++
+				} else if text[j] == ')' {
+					return text[i : j+1], true
+				}
+			}
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// find returns the index of the innermost open entity with the given opening, or -1 if there's none.
+func (s *textSplitter) find(opening string) int {
+	for i := len(s.open) - 1; i >= 0; i-- {
+		if s.open[i] == opening {
+			return i
+		}
+	}
+	return -1
+}
+
+// pop removes the open entity at index i.
+func (s *textSplitter) pop(i int) {
+	s.open = append(s.open[:i:i], s.open[i+1:]...)
+	s.openNames = append(s.openNames[:i:i], s.openNames[i+1:]...)
+}
+
+// reserve returns how much room has to be left for closing entities when some or all of text is added to a chunk.
+func (s *textSplitter) reserve(text string) int {
+	before := utf16Len(s.closing(""))
+	if after := utf16Len(s.after(text).closing("")); after > before {
+		return after
+	}
+	return before
+}
+
+// after returns a copy of the splitter, with its state updated as if text had been added.
+func (s *textSplitter) after(text string) *textSplitter {
+	copied := &textSplitter{
+		format:    s.format,
+		open:      append([]string(nil), s.open...),
+		openNames: append([]string(nil), s.openNames...),
+		text:      s.text,
+		scanned:   s.scanned,
+		pos:       s.pos,
+	}
+	copied.scan(text)
+	return copied
+}
+
+// opening returns the text that reopens the open entities at the start of a chunk.
+func (s *textSplitter) opening() string {
+	if s.format != MarkdownV2 {
+		return strings.Join(s.open, "")
+	}
+
+	opening := ""
+	for _, o := range s.open {
+		if strings.HasPrefix(o, "```") && !strings.HasSuffix(o, "\n") {
+			// A code block opened mid-line, keep the language tag out of the next chunk's code.
+			o = "```\n"
+		}
+		opening = joinMarkup(opening, o)
+	}
+	if strings.HasSuffix(opening, "_") && strings.HasPrefix(s.text[s.scanned:], "_") {
+		opening += "\r"
+	}
+	return opening
+}
+
+// closing returns the text that closes the open entities at the end of the given chunk.
+func (s *textSplitter) closing(chunk string) string {
+	var b strings.Builder
+	switch s.format {
+	case HTML:
+		for i := len(s.openNames) - 1; i >= 0; i-- {
+			b.WriteString("</" + s.openNames[i] + ">")
+		}
+	case MarkdownV2:
+		closed := chunk
+		for i := len(s.open) - 1; i >= 0; i-- {
+			if strings.HasPrefix(s.open[i], "```") && !strings.HasSuffix(closed, "\n") {
+				closed += "\n"
+			}
+			closed = joinMarkup(closed, s.openNames[i])
+		}
+		b.WriteString(closed[len(chunk):])
+	}
+	return b.String()
+}
+
+// joinMarkup adds MarkdownV2 markup to the end of text. Telegram reads "___" as the start or end of underline
+// followed by italic, so underscores of separate entities are kept apart with a carriage return, which it ignores.
+func joinMarkup(text, markup string) string {
+	if strings.HasSuffix(text, "_") && strings.HasPrefix(markup, "_") {
+		return text + "\r" + markup
+	}
+	return text + markup
+}
+
+// cut returns the longest prefix of line that fits in room, without cutting through a character,
+// an HTML tag or entity, or MarkdownV2 markup like an escape sequence or a link's URL.
+func (s *textSplitter) cut(line string, room int) string {
+	end, length := 0, 0
+	for i, r := range line {
+		length += utf16.RuneLen(r)
+		if length > room {
+			break
+		}
+		end = i + utf8.RuneLen(r)
+	}
+
+	piece := line[:end]
+	switch s.format {
+	case HTML:
+		if i := strings.LastIndexAny(piece, "<&"); i >= 0 && !strings.ContainsAny(piece[i:], ">;") {
+			piece = piece[:i]
+		}
+	case MarkdownV2:
+		// Markup that's read past the end of the piece would be split between chunks.
+		for piece != "" && s.after(piece).pos > s.scanned+len(piece) {
+			_, size := utf8.DecodeLastRuneInString(piece)
+			piece = piece[:len(piece)-size]
+		}
+	}
+
+	if piece == "" {
+		// Nothing could be cut cleanly, so cut anyway rather than looping forever.
+		_, size := utf8.DecodeRuneInString(line)
+		piece = line[:size]
+	}
+	return piece
+}
+
+// preview returns the start of text, to be shown in a caption when the full text is sent as a file.
+func preview(text string) string {
+	const previewLength = 200
+
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= previewLength {
+		return text
+	}
+
+	runes := []rune(text)[:previewLength]
+	cut := string(runes)
+	if i := strings.LastIndexByte(cut, '\n'); i > previewLength/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,145 @@
+package tell
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	cases := []struct {
+		text   string
+		format Format
+		want   string
+	}{
+		{"v1.2-rc_1 (beta)!", MarkdownV2, `v1\.2\-rc\_1 \(beta\)\!`},
+		{`C:\path`, MarkdownV2, `C:\\path`},
+		{"a < b && c", HTML, "a &lt; b &amp;&amp; c"},
+		{"*as is*", PlainText, "*as is*"},
+	}
+
+	for _, c := range cases {
+		if got := EscapeText(c.text, c.format); got != c.want {
+			t.Errorf("wrong escaping of %q for %q, expected %q, got %q", c.text, c.format, c.want, got)
+		}
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	text, mode := codeBlock("a\tb <c>\n", PlainText)
+	if mode != HTML || text != "<pre>a\tb &lt;c&gt;</pre>" {
+		t.Errorf("wrong code block for plain text: %q, %q", text, mode)
+	}
+
+	text, mode = codeBlock("echo `date` \\n.", MarkdownV2)
+	if mode != MarkdownV2 || text != "```\necho \\`date\\` \\\\n.\n```" {
+		t.Errorf("wrong code block for MarkdownV2: %q, %q", text, mode)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	long := strings.Repeat("line of output\n", 600)
+
+	chunks := splitText(long, PlainText, captionLimit, textLimit)
+	if len(chunks) < 2 {
+		t.Fatalf("expected the text to be split, got %d chunks", len(chunks))
+	}
+	if utf16Len(chunks[0]) > captionLimit {
+		t.Errorf("first chunk is %d long, longer than the limit of %d", utf16Len(chunks[0]), captionLimit)
+	}
+	for i, chunk := range chunks {
+		if utf16Len(chunk) > textLimit {
+			t.Errorf("chunk %d is %d long, longer than the limit of %d", i, utf16Len(chunk), textLimit)
+		}
+		if strings.HasPrefix(chunk, "ine") {
+			t.Errorf("chunk %d starts in the middle of a line", i)
+		}
+	}
+	if got := strings.Join(chunks, "\n"); got != strings.TrimRight(long, "\n") {
+		t.Errorf("joining the chunks doesn't give back the text")
+	}
+
+	text, mode := codeBlock(long, PlainText)
+	for i, chunk := range splitText(text, mode, textLimit, textLimit) {
+		if !strings.HasPrefix(chunk, "<pre>") || !strings.HasSuffix(chunk, "</pre>") {
+			t.Errorf("chunk %d of HTML code block isn't a complete block: %q...", i, chunk[:20])
+		}
+	}
+
+	text, mode = codeBlock(long, MarkdownV2)
+	for i, chunk := range splitText(text, mode, textLimit, textLimit) {
+		if !strings.HasPrefix(chunk, "```\n") || !strings.HasSuffix(chunk, "\n```") {
+			t.Errorf("chunk %d of MarkdownV2 code block isn't a complete block", i)
+		}
+	}
+
+	// A single line longer than the limit has to be cut, but not in the middle of a character.
+	emoji := strings.Repeat("😀", 3000)
+	for i, chunk := range splitText(emoji, PlainText, textLimit, textLimit) {
+		if utf16Len(chunk) > textLimit || !utf8.ValidString(chunk) {
+			t.Errorf("chunk %d of emoji was cut incorrectly", i)
+		}
+	}
+}
+
+func TestSplitMarkdown(t *testing.T) {
+	lines := strings.Repeat("plain \\. text\n", 300)
+	url := `](https://example.com/a\)b)`
+	text := "*bold " + lines + "bold* __underline _italic " + strings.Repeat("words ", 1000) + "italic_ underline__ ||spoiler [link " +
+		lines + url + " spoiler||"
+
+	chunks := splitText(text, MarkdownV2, textLimit, textLimit)
+	if len(chunks) < 4 {
+		t.Fatalf("expected the text to be split into at least 4 chunks, got %d", len(chunks))
+	}
+	links := 0
+	for i, chunk := range chunks {
+		if utf16Len(chunk) > textLimit {
+			t.Errorf("chunk %d is %d long, longer than the limit of %d", i, utf16Len(chunk), textLimit)
+		}
+		s := &textSplitter{format: MarkdownV2, text: chunk}
+		s.scan(chunk)
+		if len(s.open) > 0 {
+			t.Errorf("chunk %d leaves %q open", i, s.open)
+		}
+		if strings.Contains(chunk, url) {
+			links++
+		}
+	}
+	if !strings.HasPrefix(chunks[1], "*") {
+		t.Errorf("expected bold to be reopened in the second chunk, got %q...", chunks[1][:20])
+	}
+	if links < 2 {
+		t.Errorf("expected the link to be closed in each chunk it's in, found it in %d", links)
+	}
+}
+
+func TestSplitLongFormattedLine(t *testing.T) {
+	long := strings.Repeat("a", 10000)
+	for _, tt := range []struct {
+		format Format
+		text   string
+	}{
+		{HTML, "<b>" + long + "</b>"},
+		{MarkdownV2, "*" + long + "*"},
+		{MarkdownV2, "||" + long + "||"},
+		{MarkdownV2, "*bold _italic [link " + long + "](https://example.com) italic_ bold*"},
+	} {
+		for i, chunk := range splitText(tt.text, tt.format, textLimit, textLimit) {
+			if utf16Len(chunk) > textLimit {
+				t.Errorf("chunk %d of %q... is %d long, longer than the limit of %d", i, tt.text[:10], utf16Len(chunk), textLimit)
+			}
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	if got := preview("short"); got != "short" {
+		t.Errorf("short text was changed in preview: %q", got)
+	}
+
+	got := preview(strings.Repeat("a", 1000))
+	if utf8.RuneCountInString(got) != 201 || !strings.HasSuffix(got, "…") {
+		t.Errorf("wrong preview of long text: %q", got)
+	}
+}
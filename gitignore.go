@@ -0,0 +1,151 @@
+package tell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gitignore holds the rules of the .gitignore files found so far while archiving a directory.
+// Rules from deeper directories come later, so they take precedence, like they do in git.
+type gitignore []gitignoreRule
+
+type gitignoreRule struct {
+	base    string // The directory of the .gitignore file the rule is from, relative to the archived directory, "" for its root
+	re      *regexp.Regexp
+	negate  bool // True if the rule re-includes files, for patterns starting with "!"
+	dirOnly bool // True if the rule only matches directories, for patterns ending in "/"
+}
+
+// load adds the rules from the .gitignore file in a directory, if it has one. rel is the directory's path relative to
+// the archived directory.
+func (g *gitignore) load(dir, rel string) error {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open .gitignore: %w", err)
+	}
+	defer f.Close()
+
+	if rel == "." {
+		rel = ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseGitignoreLine(scanner.Text()); ok {
+			rule.base = rel
+			*g = append(*g, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+	return nil
+}
+
+// match reports whether git would ignore a file, given its slash-separated path relative to the archived directory.
+func (g gitignore) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g {
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(sub) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// parseGitignoreLine turns a line of a .gitignore file into a rule. It returns false for blank lines and comments.
+// See https://git-scm.com/docs/gitignore#_pattern_format
+func parseGitignoreLine(line string) (gitignoreRule, bool) {
+	var rule gitignoreRule
+
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// Patterns with a slash in them are relative to the .gitignore file, others match a name at any depth.
+	prefix := "^(.*/)?"
+	if strings.Contains(line, "/") {
+		prefix = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp converts a gitignore glob to a regular expression. "*" and "?" don't match slashes, "**" does.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
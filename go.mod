@@ -4,7 +4,10 @@ go 1.20
 
 require github.com/PaulSonOfLars/gotgbot/v2 v2.0.0-rc.15
 
-require github.com/spf13/pflag v1.0.5
+require (
+	github.com/klauspost/compress v1.17.9
+	github.com/spf13/pflag v1.0.5
+)
 
 require (
 	golang.org/x/mod v0.12.0 // indirect
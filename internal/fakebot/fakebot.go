@@ -0,0 +1,382 @@
+// Package fakebot provides a fake Telegram Bot API server for tests.
+package fakebot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// Token is the bot token the fake server accepts.
+const Token = "123:fake"
+
+// Server is a Bot API server for tests. It records the calls it receives, stores uploaded files so that they can
+// be downloaded again with getFile, and fails calls on request.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	calls    []Call
+	updates  []gotgbot.Update
+	files    map[string]File // Uploaded files by file ID
+	failures map[string][]apiError
+	delays   map[string][]time.Duration
+	nextID   int64
+}
+
+// Call is a request received by the fake server.
+type Call struct {
+	Method string
+	Params map[string]string
+	Files  map[string]File // Files sent in a multipart body, by field name
+}
+
+// File is a file uploaded to the fake server.
+type File struct {
+	Name string
+	Data []byte
+}
+
+type apiError struct {
+	Code        int
+	Description string
+}
+
+// New starts a fake Bot API server, which is closed when the test ends.
+func New(t testing.TB) *Server {
+	t.Helper()
+
+	f := &Server{
+		files:    map[string]File{},
+		failures: map[string][]apiError{},
+		delays:   map[string][]time.Duration{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Fail makes the next call to method fail with the given error code and description.
+// Calling it several times fails several calls in a row.
+func (f *Server) Fail(method string, code int, description string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[method] = append(f.failures[method], apiError{code, description})
+}
+
+// Delay makes the next call to method wait for d before it's answered, or until the client gives up.
+// Calling it several times delays several calls in a row.
+func (f *Server) Delay(method string, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delays[method] = append(f.delays[method], d)
+}
+
+// AddUpdate queues an update for getUpdates, numbering it automatically.
+func (f *Server) AddUpdate(u gotgbot.Update) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	u.UpdateId = f.nextID
+	f.updates = append(f.updates, u)
+}
+
+// AddFile stores a file that can be downloaded with getFile, as if a user had sent it.
+func (f *Server) AddFile(id, name string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[id] = File{name, data}
+}
+
+// Calls returns the calls made to method, or all calls if method is empty.
+func (f *Server) Calls(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []Call
+	for _, c := range f.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (f *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if id, ok := strings.CutPrefix(r.URL.Path, "/file/bot"+Token+"/"); ok {
+		f.mu.Lock()
+		file, ok := f.files[id]
+		f.mu.Unlock()
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(file.Data)
+		return
+	}
+
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+Token+"/")
+	if !ok {
+		writeAPIResponse(w, nil, &apiError{http.StatusUnauthorized, "Unauthorized"})
+		return
+	}
+
+	call, err := readAPICall(method, r)
+	if err != nil {
+		writeAPIResponse(w, nil, &apiError{http.StatusBadRequest, "Bad Request: " + err.Error()})
+		return
+	}
+
+	f.mu.Lock()
+	var delay time.Duration
+	if delays := f.delays[method]; len(delays) > 0 {
+		delay, f.delays[method] = delays[0], delays[1:]
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call)
+	if failures := f.failures[method]; len(failures) > 0 {
+		f.failures[method] = failures[1:]
+		writeAPIResponse(w, nil, &failures[0])
+		return
+	}
+
+	result, apiErr := f.handle(call)
+	writeAPIResponse(w, result, apiErr)
+}
+
+// handle returns the result of a successful call. It's called with f.mu held.
+func (f *Server) handle(call Call) (any, *apiError) {
+	switch call.Method {
+	case "getMe":
+		return gotgbot.User{Id: 1, IsBot: true, FirstName: "Tell", Username: "tell_test_bot"}, nil
+
+	case "getUpdates":
+		// Like Telegram, an offset confirms the updates before it, which are never returned again.
+		offset, _ := strconv.ParseInt(call.Params["offset"], 10, 64)
+		limit, err := strconv.Atoi(call.Params["limit"])
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 100
+		}
+		var kept []gotgbot.Update
+		for _, u := range f.updates {
+			if u.UpdateId >= offset {
+				kept = append(kept, u)
+			}
+		}
+		f.updates = kept
+
+		updates := []gotgbot.Update{}
+		for _, u := range kept {
+			if len(updates) == limit {
+				break
+			}
+			updates = append(updates, u)
+		}
+		return updates, nil
+
+	case "getFile":
+		id := call.Params["file_id"]
+		file, ok := f.files[id]
+		if !ok {
+			return nil, &apiError{http.StatusBadRequest, "Bad Request: invalid file_id"}
+		}
+		return gotgbot.File{FileId: id, FileUniqueId: id, FileSize: int64(len(file.Data)), FilePath: id}, nil
+
+	case "sendMessage", "sendDocument", "sendPhoto", "sendAudio", "sendVideo", "sendAnimation", "sendVoice", "sendVideoNote", "sendSticker":
+		chatID, err := strconv.ParseInt(call.Params["chat_id"], 10, 64)
+		if err != nil {
+			return nil, &apiError{http.StatusBadRequest, "Bad Request: chat not found"}
+		}
+
+		f.nextID++
+		msg := gotgbot.Message{
+			MessageId: f.nextID,
+			Date:      time.Now().Unix(),
+			Chat:      gotgbot.Chat{Id: chatID, Type: "private"},
+			Text:      call.Params["text"],
+			Caption:   call.Params["caption"],
+		}
+
+		if field, ok := fileFields[call.Method]; ok {
+			doc, apiErr := f.storeFile(call, call.Params[field])
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			setFile(&msg, field, doc)
+		}
+		return msg, nil
+
+	case "sendMediaGroup":
+		return f.sendMediaGroup(call)
+
+	default:
+		return true, nil
+	}
+}
+
+// sendMediaGroup stores the files of an album, and returns a message for each of them.
+func (f *Server) sendMediaGroup(call Call) (any, *apiError) {
+	chatID, err := strconv.ParseInt(call.Params["chat_id"], 10, 64)
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, "Bad Request: chat not found"}
+	}
+
+	var media []struct {
+		Type    string `json:"type"`
+		Media   string `json:"media"`
+		Caption string `json:"caption"`
+	}
+	if err := json.Unmarshal([]byte(call.Params["media"]), &media); err != nil || len(media) < 2 || len(media) > 10 {
+		return nil, &apiError{http.StatusBadRequest, "Bad Request: wrong number of media in the album"}
+	}
+
+	var msgs []gotgbot.Message
+	for _, m := range media {
+		doc, apiErr := f.storeFile(call, m.Media)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		f.nextID++
+		msg := gotgbot.Message{
+			MessageId: f.nextID,
+			Date:      time.Now().Unix(),
+			Chat:      gotgbot.Chat{Id: chatID, Type: "private"},
+			Caption:   m.Caption,
+		}
+		setFile(&msg, m.Type, doc)
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// fileFields maps the methods that send files to the parameter holding the file.
+var fileFields = map[string]string{
+	"sendDocument":  "document",
+	"sendPhoto":     "photo",
+	"sendAudio":     "audio",
+	"sendVideo":     "video",
+	"sendAnimation": "animation",
+	"sendVoice":     "voice",
+	"sendVideoNote": "video_note",
+	"sendSticker":   "sticker",
+}
+
+// setFile sets the field of a message that holds a file of the given type, like Telegram does.
+func setFile(msg *gotgbot.Message, typ string, doc *gotgbot.Document) {
+	id, uniqueID := doc.FileId, doc.FileUniqueId
+	switch typ {
+	case "photo":
+		msg.Photo = []gotgbot.PhotoSize{{FileId: id, FileUniqueId: uniqueID}}
+	case "audio":
+		msg.Audio = &gotgbot.Audio{FileId: id, FileUniqueId: uniqueID, FileName: doc.FileName}
+	case "video":
+		msg.Video = &gotgbot.Video{FileId: id, FileUniqueId: uniqueID, FileName: doc.FileName}
+	case "animation":
+		// Telegram sets both for animations, for clients that don't know about them.
+		msg.Animation = &gotgbot.Animation{FileId: id, FileUniqueId: uniqueID, FileName: doc.FileName}
+		msg.Document = doc
+	case "voice":
+		msg.Voice = &gotgbot.Voice{FileId: id, FileUniqueId: uniqueID}
+	case "video_note":
+		msg.VideoNote = &gotgbot.VideoNote{FileId: id, FileUniqueId: uniqueID}
+	case "sticker":
+		msg.Sticker = &gotgbot.Sticker{FileId: id, FileUniqueId: uniqueID}
+	default:
+		msg.Document = doc
+	}
+}
+
+// storeFile stores the file a parameter attaches, or looks up the file it references by ID.
+func (f *Server) storeFile(call Call, value string) (*gotgbot.Document, *apiError) {
+	name, ok := strings.CutPrefix(value, "attach://")
+	if !ok {
+		file, ok := f.files[value]
+		if !ok {
+			return nil, &apiError{http.StatusBadRequest, "Bad Request: wrong file identifier"}
+		}
+		return &gotgbot.Document{FileId: value, FileUniqueId: value, FileName: file.Name}, nil
+	}
+
+	file, ok := call.Files[name]
+	if !ok {
+		return nil, &apiError{http.StatusBadRequest, "Bad Request: file must be non-empty"}
+	}
+
+	f.nextID++
+	id := fmt.Sprintf("file%d", f.nextID)
+	f.files[id] = file
+	return &gotgbot.Document{FileId: id, FileUniqueId: id, FileName: file.Name, FileSize: int64(len(file.Data))}, nil
+}
+
+// readAPICall decodes the parameters of a request, which gotgbot sends as JSON, or as a multipart form when there are files.
+func readAPICall(method string, r *http.Request) (Call, error) {
+	call := Call{Method: method, Params: map[string]string{}, Files: map[string]File{}}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return call, err
+		}
+		if len(body) > 0 {
+			err = json.Unmarshal(body, &call.Params)
+		}
+		return call, err
+	}
+
+	if err := r.ParseMultipartForm(64 << 20); err != nil {
+		return call, err
+	}
+
+	for name, values := range r.MultipartForm.Value {
+		call.Params[name] = values[0]
+	}
+	for name, headers := range r.MultipartForm.File {
+		src, err := headers[0].Open()
+		if err != nil {
+			return call, err
+		}
+		data, err := io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			return call, err
+		}
+		call.Files[name] = File{headers[0].Filename, data}
+	}
+	return call, nil
+}
+
+func writeAPIResponse(w http.ResponseWriter, result any, apiErr *apiError) {
+	resp := map[string]any{"ok": apiErr == nil}
+	if apiErr != nil {
+		resp["error_code"] = apiErr.Code
+		resp["description"] = apiErr.Description
+	} else {
+		resp["result"] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
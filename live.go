@@ -0,0 +1,174 @@
+package tell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DefaultLiveInterval is the shortest time between edits of a live message, unless it's told otherwise.
+// Telegram limits how often messages can be edited, especially in groups.
+const DefaultLiveInterval = 3 * time.Second
+
+// Live is a message that's edited in place whenever its text changes, like a progress report for a long job.
+// Recipients get a single notification instead of one for every update. Create it with Client.NewLive.
+type Live struct {
+	// Interval is the shortest time between edits. Text updated sooner is held back until a later Update or Flush.
+	Interval time.Duration
+
+	client   *Client
+	template *Message // The options each version of the message is sent with
+	to       []string
+	sent     []liveMessage // The message in each recipient's chat, once it's been sent
+	text     string        // The latest text
+	sentAt   time.Time
+}
+
+// liveMessage is a live message as it was sent to one recipient.
+type liveMessage struct {
+	recipient string
+	id        int64
+	text      string // The text the message has in Telegram
+}
+
+// NewLive creates a live message for the given recipients, all authorized users if none are given.
+// Each update replaces the text of msg, its other options are kept. Nothing is sent until the first update.
+func (c *Client) NewLive(msg *Message, to ...string) *Live {
+	return &Live{
+		Interval: DefaultLiveInterval,
+		client:   c,
+		template: msg.clone(),
+		to:       to,
+	}
+}
+
+// Update replaces the text of the message. The first update sends it, the ones after it edit it, unless the previous
+// edit was less than Interval ago. Text too long for a message loses its first lines, as the latest ones matter most.
+func (l *Live) Update(ctx context.Context, text string) error {
+	l.text = text
+	if time.Since(l.sentAt) < l.Interval {
+		return nil
+	}
+	return l.Flush(ctx)
+}
+
+// Flush sends the latest text straight away, if the message doesn't have it yet.
+// Edits that fail are tried again by the next Update or Flush.
+func (l *Live) Flush(ctx context.Context) error {
+	if l.text == "" || (l.sent != nil && l.upToDate()) {
+		return nil
+	}
+	msg := l.template.clone().WithText(l.text)
+	fitLiveText(msg)
+	l.sentAt = time.Now()
+
+	if l.sent == nil {
+		results, err := l.client.send(ctx, msg, l.to)
+		for _, r := range results {
+			// Recipients the message couldn't be sent to are left out of later edits.
+			if r.Err == nil && len(r.MessageIDs) > 0 {
+				l.sent = append(l.sent, liveMessage{recipient: r.Recipient.ID, id: r.MessageIDs[0], text: l.text})
+			}
+		}
+		return err
+	}
+
+	var errs []error
+	for i, m := range l.sent {
+		if m.text == l.text {
+			continue
+		}
+		if err := l.client.Edit(ctx, m.recipient, m.id, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.recipient, err))
+			continue
+		}
+		l.sent[i].text = l.text
+	}
+	return errors.Join(errs...)
+}
+
+// upToDate reports whether the message has the latest text in every chat it was sent to.
+func (l *Live) upToDate() bool {
+	for _, m := range l.sent {
+		if m.text != l.text {
+			return false
+		}
+	}
+	return true
+}
+
+// Finish gives the message its final text, then sends status as a reply to it. Edits don't notify anyone,
+// the reply lets recipients know that the message is done.
+func (l *Live) Finish(ctx context.Context, text, status string) error {
+	l.text = text
+	err := l.Flush(ctx)
+
+	for _, m := range l.sent {
+		reply := l.template.clone().WithText(status).ReplyTo(m.id)
+		if _, sendErr := l.client.send(ctx, reply, []string{m.recipient}); sendErr != nil {
+			err = errors.Join(err, sendErr)
+		}
+	}
+	if l.sent == nil && err == nil {
+		// There was no text to send, the status is all there is.
+		_, err = l.client.send(ctx, l.template.clone().WithText(status), l.to)
+	}
+	return err
+}
+
+// fitLiveText drops the start of the message's text until it fits in a message, whole lines where possible.
+// The limit applies to the text as it's sent, after it's been wrapped in a code block and escaped.
+func fitLiveText(msg *Message) {
+	for {
+		rendered, _ := msg.render()
+		over := utf16Len(rendered) - textLimit
+		if over <= 0 {
+			return
+		}
+		if i := strings.IndexByte(msg.text, '\n'); i >= 0 {
+			msg.text = msg.text[i+1:]
+			continue
+		}
+		msg.text = dropPrefix(msg.text, over, msg.format, msg.code)
+	}
+}
+
+// dropPrefix drops at least n UTF-16 code units from the start of text, without leaving half of
+// an HTML tag or entity, or a MarkdownV2 escape sequence, behind. Code blocks are escaped after this, so
+// their text can be cut anywhere.
+func dropPrefix(text string, n int, format Format, code bool) string {
+	end, dropped := 0, 0
+	for i, r := range text {
+		if dropped >= n {
+			break
+		}
+		dropped += utf16.RuneLen(r)
+		end = i + utf8.RuneLen(r)
+	}
+	prefix, rest := text[:end], text[end:]
+	if code {
+		return rest
+	}
+
+	switch format {
+	case MarkdownV2:
+		trailing := len(prefix) - len(strings.TrimRight(prefix, `\`))
+		if trailing%2 == 1 && rest != "" {
+			// The escaped character would be left without its backslash.
+			_, size := utf8.DecodeRuneInString(rest)
+			rest = rest[size:]
+		}
+	case HTML:
+		if strings.LastIndexByte(prefix, '<') > strings.LastIndexByte(prefix, '>') {
+			rest = rest[strings.IndexByte(rest, '>')+1:]
+		}
+		if strings.LastIndexByte(prefix, '&') > strings.LastIndexByte(prefix, ';') {
+			rest = rest[strings.IndexByte(rest, ';')+1:]
+		}
+	}
+	return rest
+}
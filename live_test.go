@@ -0,0 +1,104 @@
+package tell
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLive(t *testing.T) {
+	client, api := newTestClient(t)
+	ctx := context.Background()
+
+	live := client.NewLive(NewMessage(""), "alice", "bob")
+	live.Interval = 0
+	for _, text := range []string{"Importing", "Importing\n50%", "Importing\n50%"} {
+		if err := live.Update(ctx, text); err != nil {
+			t.Fatalf("failed to update live message: %s", err)
+		}
+	}
+	if sent := api.Calls("sendMessage"); len(sent) != 2 || sent[0].Params["text"] != "Importing" {
+		t.Fatalf("expected the first update to be sent to both recipients, got %v", sent)
+	}
+	// The unchanged text isn't sent again.
+	edits := api.Calls("editMessageText")
+	if len(edits) != 2 || edits[1].Params["chat_id"] != "2" || edits[1].Params["message_id"] != "2" || edits[1].Params["text"] != "Importing\n50%" {
+		t.Fatalf("expected the second update to edit both messages, got %v", edits)
+	}
+
+	live.Interval = time.Hour
+	if err := live.Update(ctx, "Importing\n75%"); err != nil {
+		t.Fatalf("failed to update live message: %s", err)
+	}
+	if edits := api.Calls("editMessageText"); len(edits) != 2 {
+		t.Errorf("expected the update to be held back, got %d edits", len(edits))
+	}
+
+	if err := live.Finish(ctx, "Imported\n100%", "Import finished"); err != nil {
+		t.Fatalf("failed to finish live message: %s", err)
+	}
+	if edits := api.Calls("editMessageText"); len(edits) != 4 || edits[3].Params["text"] != "Imported\n100%" {
+		t.Errorf("expected the final text to be sent straight away, got %v", edits)
+	}
+	sent := api.Calls("sendMessage")
+	if len(sent) != 4 || sent[2].Params["text"] != "Import finished" || sent[2].Params["reply_to_message_id"] != "1" || sent[3].Params["reply_to_message_id"] != "2" {
+		t.Errorf("expected the status to reply to the message in each chat, got %v", sent)
+	}
+}
+
+func TestLiveFailedEdit(t *testing.T) {
+	client, api := newTestClient(t)
+	client.Retries = 0
+	ctx := context.Background()
+
+	live := client.NewLive(NewMessage(""), "alice", "bob")
+	live.Interval = 0
+	if err := live.Update(ctx, "Importing"); err != nil {
+		t.Fatalf("failed to update live message: %s", err)
+	}
+	api.Fail("editMessageText", 502, "Bad Gateway")
+	if err := live.Update(ctx, "Imported"); err == nil {
+		t.Fatalf("expected the edit to fail")
+	}
+
+	// Only the failed edit is tried again, even though the text didn't change.
+	if err := live.Finish(ctx, "Imported", "Import finished"); err != nil {
+		t.Fatalf("failed to finish live message: %s", err)
+	}
+	edits := api.Calls("editMessageText")
+	if len(edits) != 3 || edits[2].Params["chat_id"] != edits[0].Params["chat_id"] || edits[2].Params["text"] != "Imported" {
+		t.Errorf("expected the failed edit to be retried, got %v", edits)
+	}
+}
+
+func TestFitLiveText(t *testing.T) {
+	fit := func(msg *Message) string {
+		fitLiveText(msg)
+		text, _ := msg.render()
+		return text
+	}
+
+	lines := strings.Repeat(strings.Repeat("x", 99)+"\n", 50) + "last"
+	fitted := fit(NewMessage(lines))
+	if utf16Len(fitted) > textLimit || !strings.HasSuffix(fitted, "\nlast") || !strings.HasPrefix(fitted, "xxx") {
+		t.Errorf("expected whole lines to be dropped from the start, got %d characters", utf16Len(fitted))
+	}
+
+	if fitted := fit(NewMessage(strings.Repeat("é", textLimit+10))); utf16Len(fitted) != textLimit {
+		t.Errorf("expected a long line to be cut to the limit, got %d characters", utf16Len(fitted))
+	}
+
+	// Wrapping and escaping the text makes it longer, the limit applies to the result.
+	code := strings.Repeat(strings.Repeat("<", 99)+"\n", 30) + "last"
+	fitted = fit(NewMessage(code).AsCode())
+	if utf16Len(fitted) > textLimit || !strings.HasPrefix(fitted, "<pre>&lt;") || !strings.HasSuffix(fitted, "\nlast</pre>") {
+		t.Errorf("expected the code block to fit in a message, got %d characters", utf16Len(fitted))
+	}
+
+	escaped := "x" + strings.Repeat(`\.`, textLimit/2) + "y"
+	fitted = fit(NewMessage(escaped).WithFormat(MarkdownV2))
+	if utf16Len(fitted) > textLimit || !strings.HasPrefix(fitted, `\.`) {
+		t.Errorf("expected escape sequences to be kept whole, got %q...", fitted[:10])
+	}
+}
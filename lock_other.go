@@ -0,0 +1,26 @@
+//go:build !unix
+
+package tell
+
+import "sync"
+
+// fileLocks stand in for lock files where flock isn't available. They only keep out other goroutines,
+// not other processes.
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = make(map[string]*sync.Mutex)
+)
+
+// lockFile takes an exclusive lock for the file at path. The returned function releases it.
+func lockFile(path string) (unlock func(), err error) {
+	fileLocksMu.Lock()
+	mu, ok := fileLocks[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		fileLocks[path] = mu
+	}
+	fileLocksMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock, nil
+}
@@ -1,94 +1,97 @@
-package main
+package tell
 
 import (
-	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 )
 
-type messageType int
+// MessageType is the kind of Telegram message a Message is sent as.
+type MessageType int
 
-//go:generate stringer -type=messageType
+//go:generate stringer -type=MessageType -linecomment
 const (
-	textMessage messageType = iota
-	animationMessage
-	audioMessage
-	documentMessage
-	photoMessage
-	stickerMessage
-	videoMessage
-	videoNoteMessage
-	voiceMessage
-	fileUploadMessage
-	directoryMessage
+	TextMessage       MessageType = iota // text
+	AnimationMessage                     // animation
+	AudioMessage                         // audio
+	DocumentMessage                      // document
+	PhotoMessage                         // photo
+	StickerMessage                       // sticker
+	VideoMessage                         // video
+	VideoNoteMessage                     // video_note
+	VoiceMessage                         // voice
+	FileUploadMessage                    // upload
+	DirectoryMessage                     // folder
+	AlbumMessage                         // album
 )
 
-var typeInfo = map[messageType]struct {
+var typeInfo = map[MessageType]struct {
 	method     string   // The Telegram API method to use for sending this type of message
 	extensions []string // File extensions that map to this type
 	text       string   // the field in the message request to use for the message text, if any
 	file       string   // the field in the message request to use for the file, if any
 }{
-	textMessage: {
+	TextMessage: {
 		method: "sendMessage",
 		text:   "text",
 	},
-	animationMessage: {
+	AnimationMessage: {
 		method:     "sendAnimation",
 		extensions: []string{".gif"},
 		text:       "caption",
 		file:       "animation",
 	},
-	audioMessage: {
+	AudioMessage: {
 		method:     "sendAudio",
 		extensions: []string{".mp3", ".m4a"},
 		text:       "caption",
 		file:       "audio",
 	},
-	documentMessage: {
+	DocumentMessage: {
 		method: "sendDocument",
 		text:   "caption",
 		file:   "document",
 	},
-	photoMessage: {
+	PhotoMessage: {
 		method:     "sendPhoto",
 		extensions: []string{".jpg", ".jpeg", ".png"},
 		text:       "caption",
 		file:       "photo",
 	},
-	stickerMessage: {
+	StickerMessage: {
 		method:     "sendSticker",
 		extensions: []string{".webp"},
 		file:       "sticker",
 	},
-	videoMessage: {
+	VideoMessage: {
 		method:     "sendVideo",
 		extensions: []string{".mp4"},
 		text:       "caption",
 		file:       "video",
 	},
-	videoNoteMessage: {
+	VideoNoteMessage: {
 		method: "sendVideoNote",
 		file:   "video_note",
 	},
-	voiceMessage: {
+	VoiceMessage: {
 		method:     "sendVoice",
 		extensions: []string{".ogg", ".oga"},
 		file:       "voice",
 	},
 }
 
-var extensions map[string]messageType
+var extensions map[string]MessageType
 
 func init() {
-	extensions = make(map[string]messageType)
+	extensions = make(map[string]MessageType)
 	for typ, info := range typeInfo {
 		for _, ext := range info.extensions {
 			extensions[ext] = typ
@@ -96,53 +99,565 @@ func init() {
 	}
 }
 
+// ParseMessageType converts the name of a message type, like "photo" or "upload", to a MessageType.
+func ParseMessageType(typ string) (MessageType, error) {
+	for t := AnimationMessage; t <= DirectoryMessage; t++ {
+		if t.String() == typ {
+			return t, nil
+		}
+	}
+	return TextMessage, fmt.Errorf("Invalid file type: %s", typ)
+}
+
+const (
+	photoSizeLimit = 10 * 1024 * 1024
+	fileSizeLimit  = 50 * 1024 * 1024
+
+	// A self-hosted telegram-bot-api server accepts much larger files than Telegram's public one.
+	localFileSizeLimit = 2000 * 1024 * 1024
+)
+
+// SizeLimits are the largest files, in bytes, that can be sent through a Bot API server.
+// Zero fields mean Telegram's default limits.
+type SizeLimits struct {
+	File  int64 // Files larger than this are uploaded elsewhere
+	Photo int64 // Photos larger than this are sent as documents
+}
+
+func (l SizeLimits) fileLimit() int64 {
+	if l.File <= 0 {
+		return fileSizeLimit
+	}
+	return l.File
+}
+
+func (l SizeLimits) photoLimit() int64 {
+	if l.Photo <= 0 {
+		return photoSizeLimit
+	}
+	return l.Photo
+}
+
+// detectFileType decides what type of message a file is sent as, from its contents, its name and its size.
+// If the file is sent as a document even though its name suggests otherwise, reason says why.
+func detectFileType(filePath string, limits SizeLimits) (typ MessageType, reason string, err error) {
+	stat, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, "", fmt.Errorf("file %s does not exist", filePath)
+	}
+
+	if err != nil {
+		return 0, "", fmt.Errorf("error when statting file: %w", err)
+	}
+
+	if stat.IsDir() {
+		return DirectoryMessage, "", nil
+	}
+
+	extension := path.Ext(filePath)
+
+	named, ok := extensions[extension]
+	if !ok {
+		named = DocumentMessage
+	}
+
+	// The contents are more trustworthy than the name, which is only used if they aren't recognised.
+	typ, contentType, known, err := sniffFileType(filePath)
+	if err != nil {
+		return 0, "", err
+	}
+	if !known {
+		typ = named
+	} else if typ == DocumentMessage && named != DocumentMessage {
+		reason = fmt.Sprintf("has the extension %s, but contains %s", extension, contentType)
+	}
+
+	// Images that were recognised can be checked against Telegram's rules for photos, which it would reject otherwise.
+	if typ == PhotoMessage && known {
+		why, err := checkPhoto(filePath)
+		if err != nil {
+			return 0, "", err
+		}
+		if why != "" {
+			typ, reason = DocumentMessage, why
+		}
+	}
+
+	if typ == PhotoMessage && stat.Size() > limits.photoLimit() {
+		typ = DocumentMessage
+		reason = fmt.Sprintf("is larger than %dMB, the most Telegram accepts for a photo", limits.photoLimit()/1024/1024)
+	}
+
+	if stat.Size() > limits.fileLimit() {
+		typ, reason = FileUploadMessage, ""
+	}
+
+	return typ, reason, nil
+}
+
+// Overflow is what to do with text that is too long to fit in a single message.
+type Overflow string
+
+const (
+	OverflowSplit Overflow = "split" // Send the text in several messages
+	OverflowFile  Overflow = "file"  // Send the text as a file, with a preview in the caption
+)
+
+// Message is a notification to send with a Client. Create it with NewMessage, and add to it with the With methods.
 type Message struct {
-	messageType messageType
-	detected    bool // True if messageType was automatically detected
-	text        string
-	filePath    string
-	noUpload    bool // True if the file should not be uploaded to external services, even if too large for Telegram
+	text       string
+	files      []string
+	requested  MessageType // The type chosen with WithType, if typeSet
+	typeSet    bool
+	noUpload   bool     // True if the file should not be uploaded to external services, even if too large for Telegram
+	buttons    []Button // Buttons to attach to the message, their actions are registered when sending
+	split      bool     // True if files too large for Telegram should be split into parts instead of uploaded
+	encrypt    bool     // True if the file should be encrypted with the client's key
+	format     Format
+	code       bool // True if the text should be sent as a preformatted block
+	overflow   Overflow
+	archive    archiveOptions // How attached directories are archived
+	replyTo    int64          // The message this one replies to, if non-zero
+	options    SendOptions    // How the message is delivered and shown, if optionsSet
+	optionsSet bool
+	level      Level    // How severe the message is, for routing rules
+	tags       []string // Tags for routing rules
+
+	reader     io.Reader // Data to send as a file, attached with WithReader
+	readerName string
+
+	// Decided by Check.
+	typ      MessageType
+	detected bool       // True if typ was automatically detected
+	warning  string     // Why the file is sent as a document, if its name suggests otherwise
+	fallback string     // Why the file was sent as a document after Telegram rejected its detected type
+	filePath string     // The file to send, if there's exactly one
+	parts    []*Message // The messages an album is sent as, one for each file
+
+	// Set by the client while sending.
+	uploader   uploader       // Where to upload files too large for Telegram
+	encryptKey []byte         // If non-nil, the file is encrypted with this key before it's sent
+	limits     SizeLimits     // The limits of the Bot API server the message is sent through
+	manifest   *splitManifest // The parts to send, once a file has been split
+	retry      retryPolicy    // How long requests may take and how often they're retried
+	uploadURL  string         // Where the file was uploaded, if it was too large for Telegram
+	fileIDs    []string       // Telegram's IDs for the files sent to the current recipient
+}
+
+// NewMessage creates a plain text message. The text may be empty if a file is attached.
+func NewMessage(text string) *Message {
+	return &Message{text: text, overflow: OverflowSplit}
+}
+
+// WithText replaces the text of the message, or the caption if a file is attached.
+func (msg *Message) WithText(text string) *Message {
+	msg.text = text
+	return msg
 }
 
-func (msg *Message) Send(bot *gotgbot.Bot, chatID int64) error {
-	if msg.messageType == directoryMessage {
-		zipPath, remove, err := createArchive(msg.filePath)
-		if remove != nil {
-			defer remove()
+// WithFile attaches a file or a directory to the message. Directories are sent as zip archives.
+// The type of message is detected from the file, unless it's set with WithType.
+// Calling it several times attaches several files, which are grouped into albums where Telegram allows it.
+func (msg *Message) WithFile(path string) *Message {
+	msg.files = append(msg.files, path)
+	return msg
+}
+
+// WithReader attaches the data read from r as a file called name, like a file attached with WithFile. It's sent as a
+// document, or uploaded once it turns out to be too large for Telegram, without being read into memory. The data can
+// only be read once, so the message can't be sent again, or queued in an outbox.
+func (msg *Message) WithReader(name string, r io.Reader) *Message {
+	msg.reader, msg.readerName = r, name
+	return msg
+}
+
+// WithArchiveFormat sets the kind of archive attached directories are sent as. The default is ArchiveZip.
+func (msg *Message) WithArchiveFormat(format ArchiveFormat) *Message {
+	msg.archive.format = format
+	return msg
+}
+
+// ExcludeFiles leaves files matching any of the patterns out of the archives of attached directories.
+// Patterns are matched against the names of files, and their paths relative to the directory, like "node_modules" or "build/*.o".
+func (msg *Message) ExcludeFiles(patterns ...string) *Message {
+	msg.archive.exclude = append(msg.archive.exclude, patterns...)
+	return msg
+}
+
+// RespectGitignore leaves files ignored by git, and the .git directory itself, out of the archives of attached directories.
+func (msg *Message) RespectGitignore() *Message {
+	msg.archive.gitignore = true
+	return msg
+}
+
+// WithType sets the type of message the attached file is sent as.
+// Files too large for Telegram are still uploaded, whatever their type.
+func (msg *Message) WithType(typ MessageType) *Message {
+	msg.requested, msg.typeSet = typ, true
+	return msg
+}
+
+// WithFormat sets how Telegram should format the text.
+func (msg *Message) WithFormat(format Format) *Message {
+	msg.format = format
+	return msg
+}
+
+// AsCode sends the text as a preformatted block, keeping its alignment.
+func (msg *Message) AsCode() *Message {
+	msg.code = true
+	return msg
+}
+
+// WithButton adds a button which runs command on the sending machine when pressed, if 'tell -d' is running there.
+func (msg *Message) WithButton(label, command string) *Message {
+	msg.buttons = append(msg.buttons, Button{Label: label, Command: command})
+	return msg
+}
+
+// WithOverflow sets what to do with text too long for a single message. The default is OverflowSplit.
+func (msg *Message) WithOverflow(overflow Overflow) *Message {
+	msg.overflow = overflow
+	return msg
+}
+
+// ReplyTo sends the message as a reply to an earlier one. Message IDs are only unique within a chat, so recipients
+// who don't have a message with this ID get the message without the reply.
+func (msg *Message) ReplyTo(messageID int64) *Message {
+	msg.replyTo = messageID
+	return msg
+}
+
+// WithoutUpload makes sending fail instead of uploading files too large for Telegram to an external service.
+func (msg *Message) WithoutUpload() *Message {
+	msg.noUpload = true
+	return msg
+}
+
+// SplitLargeFiles sends files too large for Telegram in parts instead of uploading them.
+// 'tell -r' joins the parts again.
+func (msg *Message) SplitLargeFiles() *Message {
+	msg.split = true
+	return msg
+}
+
+// Encrypted encrypts the attached file with the client's key before sending it. It is sent as a document.
+func (msg *Message) Encrypted() *Message {
+	msg.encrypt = true
+	return msg
+}
+
+// Text returns the text of the message.
+func (msg *Message) Text() string {
+	return msg.text
+}
+
+// Type returns the type the message will be sent as. It's only known after Check.
+func (msg *Message) Type() MessageType {
+	return msg.typ
+}
+
+// Warnings returns the reasons attached files are sent as documents even though their names suggest otherwise,
+// like photos Telegram would reject. They're only known after Check.
+func (msg *Message) Warnings() []string {
+	var warnings []string
+	if msg.warning != "" {
+		warnings = append(warnings, msg.warning)
+	}
+	for _, part := range msg.parts {
+		warnings = append(warnings, part.Warnings()...)
+	}
+	return warnings
+}
+
+// Check decides how the message will be sent through a Bot API server with the given limits, and reports any reason
+// it can't be sent. Client.Send checks messages itself, calling Check beforehand lets problems be found earlier.
+func (msg *Message) Check(limits SizeLimits) error {
+	if msg.overflow != OverflowSplit && msg.overflow != OverflowFile {
+		return fmt.Errorf("invalid overflow policy: %s", msg.overflow)
+	}
+
+	if _, err := ParseArchiveFormat(string(msg.archive.formatOrDefault())); err != nil {
+		return err
+	}
+
+	msg.filePath, msg.parts, msg.warning, msg.fallback = "", nil, "", ""
+	if len(msg.files) > 1 {
+		return msg.checkAlbum(limits)
+	}
+	if len(msg.files) == 1 {
+		msg.filePath = msg.files[0]
+	}
+	if msg.reader != nil {
+		return msg.checkReader()
+	}
+
+	if msg.filePath == "" {
+		if msg.encrypt || msg.split || msg.noUpload || msg.typeSet {
+			return fmt.Errorf("file options are set, but no file is attached")
 		}
+		msg.typ = TextMessage
+		return nil
+	}
 
+	// Split files are never uploaded, so not uploading is already taken care of.
+	noUpload := msg.noUpload && !msg.split
+
+	detected, reason, err := detectFileType(msg.filePath, limits)
+	if err != nil {
+		return err
+	}
+
+	if !msg.typeSet {
+		if detected == FileUploadMessage && noUpload {
+			return fmt.Errorf("file is too big to send via Telegram and uploads are disabled")
+		}
+		msg.detected = true
+		msg.typ = detected
+		if reason != "" {
+			msg.warning = fmt.Sprintf("%s %s, sending it as a document instead", msg.filePath, reason)
+		}
+	} else {
+		if msg.requested == FileUploadMessage && (msg.noUpload || msg.split) {
+			return fmt.Errorf("files of type upload can't be sent without uploading them")
+		}
+
+		// detected is only equal to FileUploadMessage if the file is too large.
+		if detected == FileUploadMessage && noUpload {
+			return fmt.Errorf("file is too big to send via Telegram and uploads are disabled")
+		}
+
+		if detected == FileUploadMessage {
+			msg.typ = FileUploadMessage // Too large to send with the chosen type, forced override
+		} else {
+			msg.typ = msg.requested
+		}
+	}
+
+	// Telegram can't show encrypted photos, voice messages etc., so they can only be sent as documents.
+	if msg.encrypt {
+		switch msg.typ {
+		case DocumentMessage, FileUploadMessage, DirectoryMessage:
+		default:
+			if !msg.detected {
+				return fmt.Errorf("encrypted files can't be sent with message type %s", msg.typ)
+			}
+			msg.typ = DocumentMessage
+		}
+	}
+
+	if info, ok := typeInfo[msg.typ]; ok {
+		if info.text == "" && msg.text != "" {
+			return fmt.Errorf("sending text is not supported with message type %s", msg.typ)
+		}
+		if info.file == "" {
+			return fmt.Errorf("sending files is not supported with message type %s", msg.typ)
+		}
+	}
+
+	return nil
+}
+
+// clone returns a copy of the message that can be prepared for sending without changing the original.
+func (msg *Message) clone() *Message {
+	m := *msg
+	m.files = append([]string(nil), msg.files...)
+	m.buttons = append([]Button(nil), msg.buttons...)
+	m.tags = append([]string(nil), msg.tags...)
+	m.archive.exclude = append([]string(nil), msg.archive.exclude...)
+	return &m
+}
+
+// prepare turns the message into one Telegram can accept, by archiving directories, encrypting files and uploading large files.
+// The returned cleanup function, if non-nil, removes any temporary files and should be called after sending.
+func (msg *Message) prepare(ctx context.Context) (cleanup func(), err error) {
+	if msg.typ == AlbumMessage {
+		return msg.prepareAlbum(ctx)
+	}
+
+	// Archiving and encrypting change the size of the file, so its type has to be decided again afterwards.
+	resized := false
+
+	if msg.reader != nil {
+		uploaded, remove, err := msg.spoolReader(ctx)
+		cleanup = remove
+		if uploaded || err != nil {
+			return cleanup, err
+		}
+	}
+
+	if msg.typ == DirectoryMessage {
+		uploaded, remove, err := msg.spoolArchive(ctx)
+		cleanup = remove
+		if uploaded || err != nil {
+			return cleanup, err
+		}
+		resized = true
+	}
+
+	if msg.encryptKey != nil && msg.filePath != "" {
+		encPath, remove, err := encryptFile(msg.filePath, msg.encryptKey)
+		cleanup = chainCleanup(cleanup, remove)
 		if err != nil {
-			return fmt.Errorf("failed to create archive: %w", err)
+			return cleanup, err
 		}
-		msg.filePath = zipPath
+		msg.filePath = encPath
+		resized = true
+	}
 
-		stat, err := os.Lstat(zipPath)
+	if resized {
+		stat, err := os.Lstat(msg.filePath)
 		if err != nil {
-			return fmt.Errorf("failed to stat zip file: %w", err)
+			return cleanup, fmt.Errorf("failed to stat file: %w", err)
 		}
 		size := stat.Size()
-		if size < fileSizeLimit || msg.noUpload {
-			msg.messageType = documentMessage
+		if size < msg.limits.fileLimit() || (msg.noUpload && !msg.split) {
+			msg.typ = DocumentMessage
 		} else {
-			msg.messageType = fileUploadMessage
+			msg.typ = FileUploadMessage
 		}
 	}
 
-	if msg.messageType == fileUploadMessage {
-		url, err := uploadFile(msg.filePath)
+	if msg.typ == FileUploadMessage && msg.split {
+		manifest, remove, err := splitFile(msg.filePath, splitPartSize)
+		cleanup = chainCleanup(cleanup, remove)
 		if err != nil {
-			return fmt.Errorf("failed to upload file: %w", err)
+			return cleanup, fmt.Errorf("failed to split file: %w", err)
 		}
+		msg.manifest = manifest
+		msg.typ = DocumentMessage
+	}
 
-		if msg.text != "" {
-			msg.text += "\n"
+	if msg.typ == FileUploadMessage {
+		up := msg.uploaderOrDefault()
+
+		var url string
+		err := msg.retry.do(ctx, func(ctx context.Context) error {
+			var err error
+			url, err = up.upload(ctx, msg.filePath)
+			return err
+		})
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to upload file: %w", err)
 		}
+		msg.linkUpload(url)
+	}
+
+	return cleanup, nil
+}
+
+// uploaderOrDefault returns the uploader set by the client, or the default one if there's none.
+func (msg *Message) uploaderOrDefault() uploader {
+	if msg.uploader == nil {
+		up, _ := newUploader(UploadConfig{})
+		return up
+	}
+	return msg.uploader
+}
+
+// spoolArchive archives the attached directory into a temporary file, which becomes the message's file.
+// If the archive turns out to be too large for Telegram and the uploader can take a stream, the rest of it is
+// archived straight into the upload instead, and the directory is reported as uploaded.
+func (msg *Message) spoolArchive(ctx context.Context) (uploaded bool, remove func(), err error) {
+	if _, ok := msg.uploaderOrDefault().(streamUploader); !ok || msg.encryptKey != nil || msg.split || msg.noUpload {
+		archivePath, remove, err := createArchive(ctx, msg.filePath, msg.archive)
+		if err != nil {
+			return false, remove, fmt.Errorf("failed to create archive: %w", err)
+		}
+		msg.filePath = archivePath
+		return false, remove, nil
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		err := writeArchive(ctx, w, msg.filePath, msg.archive)
+		if err != nil {
+			err = fmt.Errorf("failed to create archive: %w", err)
+		}
+		w.CloseWithError(err)
+	}()
+	defer r.Close() // Stops the archive from being written if it wasn't read to the end
+
+	// The archive is read like a stream given to WithReader, only the part that fits in Telegram is kept on disk.
+	msg.reader, msg.readerName = r, msg.archive.name(msg.filePath)
+	defer func() { msg.reader = nil }()
+	return msg.spoolReader(ctx)
+}
+
+// linkUpload replaces the message's file with a link to where it was uploaded.
+func (msg *Message) linkUpload(url string) {
+	msg.uploadURL = url
+	if msg.text != "" {
+		msg.text += "\n"
+	}
+	if msg.code {
+		// The link is escaped together with the rest of the code block.
 		msg.text += url
-		msg.filePath = ""
-		msg.messageType = textMessage
+	} else {
+		msg.text += EscapeText(url, msg.format)
 	}
+	msg.filePath = ""
+	msg.typ = TextMessage
+}
+
+// chainCleanup returns a cleanup function that calls both a and b, either of which may be nil.
+func chainCleanup(a, b func()) func() {
+	if a == nil || b == nil {
+		if a == nil {
+			return b
+		}
+		return a
+	}
+	return func() {
+		b()
+		a()
+	}
+}
+
+// send sends a prepared message to a chat, and returns the IDs of the messages sent.
+// Text that is too long for Telegram is split into several messages, or sent as a file, depending on msg.overflow.
+func (msg *Message) send(ctx context.Context, bot *gotgbot.Bot, chatID int64) ([]int64, error) {
+	if msg.typ == AlbumMessage {
+		return msg.sendAlbum(ctx, bot, chatID)
+	}
+	if msg.manifest != nil {
+		return msg.sendParts(ctx, bot, chatID)
+	}
+
+	// If Telegram rejects the file as the type it was detected as, it's sent again as a document.
+	// Later recipients get the document straight away.
+	ids, err := msg.sendSingle(ctx, bot, chatID)
+	if ids == nil && msg.fallBack(err) {
+		return msg.sendSingle(ctx, bot, chatID)
+	}
+	return ids, err
+}
+
+// sendSingle sends a prepared message with at most one file, as the message's type.
+func (msg *Message) sendSingle(ctx context.Context, bot *gotgbot.Bot, chatID int64) ([]int64, error) {
+	typ := typeInfo[msg.typ]
+	text, format := msg.render()
+
+	// Text that doesn't fit in the message itself, to be sent afterwards.
+	var followUps []string
+	var overflowFile bool
+
+	if typ.text != "" {
+		limit := textLimit
+		if typ.text == "caption" {
+			limit = captionLimit
+		}
 
-	typ := typeInfo[msg.messageType]
+		if utf16Len(text) > limit {
+			if msg.overflow == OverflowFile {
+				overflowFile = true
+				text, format = preview(msg.text), PlainText
+			} else {
+				chunks := splitText(text, format, limit, textLimit)
+				text, followUps = chunks[0], chunks[1:]
+			}
+		}
+	}
 
 	// Constructing the *Opts structs for each message type is a bit of a pain, it's easier to just use the lower-level Request API here.
 	params := map[string]string{
@@ -153,7 +668,7 @@ func (msg *Message) Send(bot *gotgbot.Bot, chatID int64) error {
 	if msg.filePath != "" {
 		f, err := os.Open(msg.filePath)
 		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
+			return nil, fmt.Errorf("failed to open file: %w", err)
 		}
 		defer f.Close()
 		params[typ.file] = "attach://" + typ.file
@@ -162,120 +677,159 @@ func (msg *Message) Send(bot *gotgbot.Bot, chatID int64) error {
 		}
 	}
 
-	if typ.text != "" {
-		params[typ.text] = msg.text
+	if overflowFile && msg.filePath == "" {
+		// There's no file to show the preview with, so the text file takes the message's place.
+		typ = typeInfo[DocumentMessage]
+		params[typ.file] = "attach://" + typ.file
+		data = map[string]gotgbot.NamedReader{
+			typ.file: overflowTextFile(msg.text),
+		}
+		overflowFile = false
 	}
 
-	_, err := bot.Request(typ.method, params, data, nil)
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+	if typ.text != "" {
+		params[typ.text] = text
+		if format != PlainText {
+			params["parse_mode"] = string(format)
+		}
 	}
-	return nil
-}
 
-// uploadFile uploads a (potentially large) file to transfer.sh
-func uploadFile(filePath string) (string, error) {
-	f, err := os.Open(filePath)
+	// Buttons go on the last message, so that they're shown below all of the text.
+	markup, err := msg.replyMarkup()
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+		return nil, err
+	}
+	if markup != "" && len(followUps) == 0 && !overflowFile {
+		params["reply_markup"] = markup
 	}
-	defer f.Close()
 
-	name := url.PathEscape(f.Name())
-	req, err := http.NewRequest("PUT", "https://transfer.sh/"+name, f)
+	var ids []int64
+	sent, err := msg.sendRequest(ctx, bot, typ.method, params, data)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
+	ids = append(ids, sent.MessageId)
 
-	req.Header.Set("User-Agent", "Tell (https://github.com/mikolysz/tell)")
-	req.Header.Set("Content-Type", "application/octet-stream")
+	if overflowFile {
+		params := map[string]string{
+			"chat_id":  fmt.Sprint(chatID),
+			"document": "attach://document",
+		}
+		if markup != "" {
+			params["reply_markup"] = markup
+		}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to upload file to transfer.sh: %w", err)
+		data := map[string]gotgbot.NamedReader{"document": overflowTextFile(msg.text)}
+		sent, err := msg.sendRequest(ctx, bot, "sendDocument", params, data)
+		if err != nil {
+			return ids, fmt.Errorf("failed to send text file: %w", err)
+		}
+		ids = append(ids, sent.MessageId)
 	}
-	defer resp.Body.Close()
 
-	url, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read transfer.sh response: %w", err)
+	for i, chunk := range followUps {
+		params := map[string]string{
+			"chat_id": fmt.Sprint(chatID),
+			"text":    chunk,
+		}
+		if format != PlainText {
+			params["parse_mode"] = string(format)
+		}
+		if markup != "" && i == len(followUps)-1 {
+			params["reply_markup"] = markup
+		}
+
+		sent, err := msg.sendRequest(ctx, bot, "sendMessage", params, nil)
+		if err != nil {
+			return ids, fmt.Errorf("failed to send part %d of the text: %w", i+2, err)
+		}
+		ids = append(ids, sent.MessageId)
 	}
 
-	return string(url), nil
+	return ids, nil
 }
 
-// createArchive creates a zip archive of a directory
-func createArchive(srcDirPath string) (zipPath string, remove func(), err error) {
-	tmpdir, err := os.MkdirTemp("", "tell")
+// sendRequest calls a Bot API method that sends a message, and returns the message that was sent.
+func (msg *Message) sendRequest(ctx context.Context, bot *gotgbot.Bot, method string, params map[string]string, data map[string]gotgbot.NamedReader) (*gotgbot.Message, error) {
+	res, err := msg.request(ctx, bot, method, params, data)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+		return nil, err
 	}
 
-	remove = func() {
-		os.RemoveAll(tmpdir)
+	var sent gotgbot.Message
+	if err := json.Unmarshal(res, &sent); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", method, err)
 	}
+	msg.recordSent(&sent)
+	return &sent, nil
+}
 
-	srcDirPath, err = filepath.Abs(srcDirPath)
-	if err != nil {
-		return "", remove, fmt.Errorf("failed to get absolute path: %w", err)
+// request calls a Bot API method that sends the message, with its options and as a reply if it is one.
+func (msg *Message) request(ctx context.Context, bot *gotgbot.Bot, method string, params map[string]string, data map[string]gotgbot.NamedReader) (json.RawMessage, error) {
+	msg.addOptions(method, params)
+	if msg.replyTo != 0 {
+		params["reply_to_message_id"] = fmt.Sprint(msg.replyTo)
+		params["allow_sending_without_reply"] = "true"
 	}
+	return msg.retry.request(ctx, bot, method, params, data)
+}
 
-	zipPath = filepath.Join(tmpdir, filepath.Base(srcDirPath)+".zip")
-	zipFile, err := os.Create(zipPath)
-	if err != nil {
-		return "", remove, fmt.Errorf("failed to create zip file: %w", err)
-	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	if err := filepath.Walk(srcDirPath, func(srcFilePath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return fmt.Errorf("failed to walk directory: %w", err)
-		}
-
-		destPath, err := filepath.Rel(srcDirPath, srcFilePath)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
+// rewind seeks the files in data back to their start, so that they can be sent again.
+func rewind(data map[string]gotgbot.NamedReader) error {
+	for field, r := range data {
+		var src any = r
+		if f, ok := r.(gotgbot.NamedFile); ok {
+			src = f.File
 		}
 
-		// Package zip distinguishes files and directories by whether the path ends in a '/'.
-		if info.IsDir() && !strings.HasSuffix(destPath, "/") {
-			destPath += "/"
+		seeker, ok := src.(io.Seeker)
+		if !ok {
+			return fmt.Errorf("can't send %s again, it can't be rewound", field)
 		}
-
-		fh, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return fmt.Errorf("Failed to create file header: %w", err)
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind %s: %w", field, err)
 		}
+	}
+	return nil
+}
 
-		fh.Name = destPath
-		fh.Method = zip.Deflate
-		fileWriter, err := zipWriter.CreateHeader(fh)
-		if err != nil {
-			return fmt.Errorf("failed to create zip file: %w", err)
-		}
+// render returns the message's text as it should be sent, and the format to send it with.
+func (msg *Message) render() (string, Format) {
+	if msg.code {
+		return codeBlock(msg.text, msg.format)
+	}
+	return msg.text, msg.format
+}
 
-		if info.IsDir() {
-			return nil
-		}
+// overflowTextFile returns text as a file, for text too long to be sent as a message.
+func overflowTextFile(text string) gotgbot.NamedReader {
+	return gotgbot.NamedFile{
+		File:     strings.NewReader(text),
+		FileName: "message.txt",
+	}
+}
 
-		srcFile, err := os.Open(srcFilePath)
-		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
-		}
-		defer srcFile.Close()
+// replyMarkup returns the inline keyboard for the message's buttons, one button per row.
+// It returns an empty string if the message has no buttons.
+func (msg *Message) replyMarkup() (string, error) {
+	if len(msg.buttons) == 0 {
+		return "", nil
+	}
 
-		_, err = io.Copy(fileWriter, srcFile)
-		if err != nil {
-			return fmt.Errorf("failed to copy file to zip: %w", err)
+	var keyboard gotgbot.InlineKeyboardMarkup
+	for _, b := range msg.buttons {
+		if b.token == "" {
+			return "", fmt.Errorf("action for button %q was not registered", b.Label)
 		}
-
-		return nil
-	}); err != nil {
-		return "", remove, fmt.Errorf("failed to walk directory: %w", err)
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []gotgbot.InlineKeyboardButton{{
+			Text:         b.Label,
+			CallbackData: b.token,
+		}})
 	}
 
-	return zipPath, remove, nil
+	markup, err := json.Marshal(keyboard)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode buttons: %w", err)
+	}
+	return string(markup), nil
 }
@@ -0,0 +1,101 @@
+package tell
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	photo, cleanup, err := tempFile("jpg", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	largePhoto, cleanupLarge, err := tempFile("jpg", photoSizeLimit+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupLarge()
+
+	cases := []struct {
+		msg    *Message
+		limits SizeLimits
+		want   MessageType
+	}{
+		{NewMessage("Hello"), SizeLimits{}, TextMessage},
+		{NewMessage("").WithFile("testdata/foo"), SizeLimits{}, DocumentMessage},
+		{NewMessage("").WithFile("testdata"), SizeLimits{}, DirectoryMessage},
+		{NewMessage("").WithFile(photo), SizeLimits{}, PhotoMessage},
+		{NewMessage("").WithFile(photo).Encrypted(), SizeLimits{}, DocumentMessage},
+		{NewMessage("").WithFile(largePhoto), SizeLimits{}, DocumentMessage},
+		{NewMessage("").WithFile(largePhoto), SizeLimits{Photo: 2 * photoSizeLimit}, PhotoMessage},
+		{NewMessage("").WithFile(largePhoto), SizeLimits{File: 1024}, FileUploadMessage},
+		{NewMessage("").WithFile(largePhoto).SplitLargeFiles().WithoutUpload(), SizeLimits{File: 1024}, FileUploadMessage},
+		{NewMessage("").WithFile(largePhoto).WithType(AudioMessage), SizeLimits{File: 1024}, FileUploadMessage},
+		{NewMessage("").WithFile("testdata/foo").WithType(AudioMessage), SizeLimits{}, AudioMessage},
+	}
+
+	for i, c := range cases {
+		if err := c.msg.Check(c.limits); err != nil {
+			t.Errorf("case %d: check failed: %s", i, err)
+			continue
+		}
+		if c.msg.Type() != c.want {
+			t.Errorf("case %d: wrong type, expected %s, got %s", i, c.want, c.msg.Type())
+		}
+	}
+
+	invalid := []*Message{
+		NewMessage("Hello").Encrypted(),                                            // nothing to encrypt
+		NewMessage("").WithFile("this_file_does_not_exist"),                        // no such file
+		NewMessage("").WithFile(largePhoto).WithoutUpload(),                        // too large and can't be uploaded
+		NewMessage("").WithFile("testdata/foo").WithType(PhotoMessage).Encrypted(), // encrypted photos can't be shown
+		NewMessage("Caption").WithFile("testdata/foo").WithType(VoiceMessage),      // voice messages have no caption
+		NewMessage("Hello").WithOverflow("truncate"),
+	}
+
+	for i, msg := range invalid {
+		if err := msg.Check(SizeLimits{File: 1024}); err == nil {
+			t.Errorf("invalid message %d: check succeeded", i)
+		}
+	}
+}
+
+func TestParseMessageType(t *testing.T) {
+	if typ, err := ParseMessageType("video_note"); err != nil || typ != VideoNoteMessage {
+		t.Errorf("wrong type for video_note, got %s, %v", typ, err)
+	}
+	if _, err := ParseMessageType("text"); err == nil {
+		t.Errorf("text accepted as a file type")
+	}
+}
+
+// tempFile creates a temporary file with the given size and extension.
+// The file is filled with garbage contents.
+// It returns the path to the file and a function that can be used to remove it.
+func tempFile(extension string, sizeBytes int) (string, func(), error) {
+	f, err := os.CreateTemp("", "tell_test_*."+extension)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	remove := func() {
+		_ = os.Remove(f.Name())
+	}
+
+	// Fill the file with garbage.
+	_, err = f.Write(make([]byte, sizeBytes))
+	if err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	return f.Name(), remove, nil
+}
@@ -0,0 +1,45 @@
+package tell
+
+import "strings"
+
+// SendOptions control how Telegram delivers and shows a message. The zero value keeps Telegram's defaults.
+type SendOptions struct {
+	Silent    bool `json:"silent,omitempty"`     // Deliver the message without a notification sound
+	Protect   bool `json:"protect,omitempty"`    // Keep the message from being forwarded or saved
+	NoPreview bool `json:"no_preview,omitempty"` // Don't show previews of links in the text
+	Spoiler   bool `json:"spoiler,omitempty"`    // Cover photos, videos and animations until they're tapped
+}
+
+// spoilerMethods are the methods that send files which can be covered with a spoiler.
+var spoilerMethods = map[string]bool{"sendPhoto": true, "sendVideo": true, "sendAnimation": true}
+
+// WithOptions sets how the message is delivered and shown, instead of the defaults from the client's config.
+func (msg *Message) WithOptions(opts SendOptions) *Message {
+	msg.options = opts
+	msg.optionsSet = true
+	return msg
+}
+
+// addOptions adds the message's options that apply to method to its params. Options that don't apply, like
+// a spoiler on a document, are left out, so that defaults from the config work with every kind of message.
+func (msg *Message) addOptions(method string, params map[string]string) {
+	if strings.HasPrefix(method, "send") {
+		if msg.options.Silent {
+			params["disable_notification"] = "true"
+		}
+		if msg.options.Protect {
+			params["protect_content"] = "true"
+		}
+	}
+	if msg.options.NoPreview && (method == "sendMessage" || method == "editMessageText") {
+		params["link_preview_options"] = `{"is_disabled":true}`
+	}
+	if msg.options.Spoiler && spoilerMethods[method] {
+		params["has_spoiler"] = "true"
+	}
+}
+
+// spoilerInAlbum reports whether the file should be covered with a spoiler when it's part of an album.
+func (msg *Message) spoilerInAlbum() bool {
+	return msg.options.Spoiler && (msg.typ == PhotoMessage || msg.typ == VideoMessage)
+}
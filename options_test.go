@@ -0,0 +1,51 @@
+package tell
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSendOptions(t *testing.T) {
+	client, api := newTestClient(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	photo := pngFile(t, dir, "chart.png", 64, 64)
+
+	opts := SendOptions{Silent: true, Protect: true, NoPreview: true, Spoiler: true}
+	if _, err := client.Send(ctx, NewMessage("See https://example.com").WithOptions(opts), "alice"); err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+	p := api.Calls("sendMessage")[0].Params
+	if p["disable_notification"] != "true" || p["protect_content"] != "true" || p["link_preview_options"] != `{"is_disabled":true}` || p["has_spoiler"] != "" {
+		t.Errorf("wrong options for a text message, got %v", p)
+	}
+
+	if _, err := client.Send(ctx, NewMessage("").WithFile(photo).WithOptions(opts), "alice"); err != nil {
+		t.Fatalf("failed to send photo: %s", err)
+	}
+	if p := api.Calls("sendPhoto")[0].Params; p["has_spoiler"] != "true" || p["link_preview_options"] != "" {
+		t.Errorf("wrong options for a photo, got %v", p)
+	}
+
+	second := pngFile(t, dir, "second.png", 64, 64)
+	if _, err := client.Send(ctx, NewMessage("").WithFile(photo).WithFile(second).WithOptions(opts), "alice"); err != nil {
+		t.Fatalf("failed to send album: %s", err)
+	}
+	if p := api.Calls("sendMediaGroup")[0].Params; p["disable_notification"] != "true" || strings.Count(p["media"], `"has_spoiler":true`) != 2 {
+		t.Errorf("wrong options for an album, got %v", p)
+	}
+
+	// The config's defaults apply unless the message has its own options.
+	client.cfg.Defaults = SendOptions{Silent: true}
+	if _, err := client.Send(ctx, NewMessage("Nightly report"), "alice"); err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+	if _, err := client.Send(ctx, NewMessage("Nightly build failed").WithOptions(SendOptions{}), "alice"); err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+	sent := api.Calls("sendMessage")
+	if sent[1].Params["disable_notification"] != "true" || sent[2].Params["disable_notification"] != "" {
+		t.Errorf("expected the default to be overridden by the message's options, got %v", sent[1:])
+	}
+}
@@ -0,0 +1,452 @@
+package tell
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultOutboxMaxAge is how long queued messages are kept, unless the outbox is told otherwise.
+// An alert that's a day late is rarely worth sending.
+const DefaultOutboxMaxAge = 24 * time.Hour
+
+// Outbox is a directory holding messages that couldn't be sent, together with copies of their files.
+// Set it as a client's Outbox to queue messages automatically, and deliver them with Client.Flush.
+type Outbox struct {
+	Dir    string
+	MaxAge time.Duration // Older messages are dropped instead of delivered. Zero means no limit
+}
+
+// OutboxItem is a message waiting in an outbox.
+type OutboxItem struct {
+	ID        string    `json:"id"`
+	Queued    time.Time `json:"queued"`
+	To        []string  `json:"to"` // The recipients the message hasn't been delivered to yet
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+
+	Text  string   `json:"-"`
+	Files []string `json:"-"` // The names of the attached files
+}
+
+// outboxEntry is an item as it's stored in the outbox, in <id>.json. Its file is kept in the <id> directory.
+type outboxEntry struct {
+	OutboxItem
+	Key     string        `json:"key"` // Identifies the message's contents, so that the same message is only queued once
+	Message queuedMessage `json:"message"`
+}
+
+// queuedMessage holds the parts of a Message set with its builder methods.
+type queuedMessage struct {
+	Text     string       `json:"text,omitempty"`
+	Files    []string     `json:"files,omitempty"` // The files' paths, relative to the entry's directory
+	Type     string       `json:"type,omitempty"`  // The type chosen with WithType
+	NoUpload bool         `json:"no_upload,omitempty"`
+	Buttons  []Button     `json:"buttons,omitempty"`
+	Split    bool         `json:"split,omitempty"`
+	Encrypt  bool         `json:"encrypt,omitempty"`
+	Format   Format       `json:"format,omitempty"`
+	Code     bool         `json:"code,omitempty"`
+	Overflow Overflow     `json:"overflow,omitempty"`
+	ReplyTo  int64        `json:"reply_to,omitempty"`
+	Options  *SendOptions `json:"options,omitempty"` // Nil if the config's defaults are used
+	Level    Level        `json:"level,omitempty"`
+	Tags     []string     `json:"tags,omitempty"`
+}
+
+// FlushReport counts what happened to the messages in an outbox when it was flushed.
+type FlushReport struct {
+	Delivered int // Sent to all their recipients
+	Failed    int // Rejected by Telegram for some recipients, and dropped
+	Expired   int // Older than the outbox's MaxAge, and dropped
+	Pending   int // Still waiting, because Telegram couldn't be reached
+}
+
+// DefaultOutboxPath returns where messages that couldn't be sent are queued, ~/.tell_outbox.
+func DefaultOutboxPath() (string, error) {
+	dirname, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	return dirname + "/.tell_outbox", nil
+}
+
+// NewOutbox returns the outbox in dir, which is created when the first message is queued.
+func NewOutbox(dir string) *Outbox {
+	return &Outbox{Dir: dir, MaxAge: DefaultOutboxMaxAge}
+}
+
+// Add queues a message for the recipients with the given IDs, or all authorized users if there are none. The attached file is copied, so it may change or
+// disappear before the message is delivered, and directories are archived right away.
+// If the same message is already queued, the recipients are added to it instead.
+func (o *Outbox) Add(msg *Message, to []string) (err error) {
+	if msg.reader != nil {
+		return fmt.Errorf("messages with data from a reader can't be queued, it has already been read")
+	}
+	if err := os.MkdirAll(o.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create outbox: %w", err)
+	}
+
+	id, err := newOutboxID()
+	if err != nil {
+		return err
+	}
+
+	entry := &outboxEntry{
+		OutboxItem: OutboxItem{ID: id, Queued: time.Now(), To: to},
+		Message: queuedMessage{
+			Text:     msg.text,
+			NoUpload: msg.noUpload,
+			Buttons:  msg.buttons,
+			Split:    msg.split,
+			Encrypt:  msg.encrypt,
+			Format:   msg.format,
+			Code:     msg.code,
+			Overflow: msg.overflow,
+			ReplyTo:  msg.replyTo,
+			Level:    msg.level,
+			Tags:     msg.tags,
+		},
+	}
+	if msg.typeSet {
+		entry.Message.Type = msg.requested.String()
+	}
+	if msg.optionsSet {
+		opts := msg.options
+		entry.Message.Options = &opts
+	}
+
+	hash := sha256.New()
+	if len(msg.files) > 0 {
+		defer func() {
+			if err != nil {
+				os.RemoveAll(filepath.Join(o.Dir, id))
+			}
+		}()
+	}
+	for i, path := range msg.files {
+		// Each file gets its own directory, as files from different directories may have the same name.
+		name, err := o.copyFile(filepath.Join(id, fmt.Sprint(i)), path, msg.archive, hash)
+		if err != nil {
+			return fmt.Errorf("failed to copy file to outbox: %w", err)
+		}
+		entry.Message.Files = append(entry.Message.Files, filepath.Join(fmt.Sprint(i), name))
+	}
+	if entry.Message.Type == DirectoryMessage.String() {
+		entry.Message.Type = "" // They're archives now
+	}
+
+	// The key covers everything but the ID and the time, so that a cron job failing every minute queues one alert.
+	keyData, err := json.Marshal(entry.Message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	hash.Write(keyData)
+	entry.Key = hex.EncodeToString(hash.Sum(nil))
+
+	// The copied files aren't part of the outbox until the entry is saved, only this needs to wait for a flush.
+	unlock, err := o.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := o.load()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Key == entry.Key {
+			os.RemoveAll(filepath.Join(o.Dir, id))
+			e.To = union(e.To, to)
+			return o.save(e)
+		}
+	}
+
+	return o.save(entry)
+}
+
+// copyFile copies a file into dir, relative to the outbox, archiving directories as set by archive, and returns its name.
+// The file's contents are written to hash as well.
+func (o *Outbox) copyFile(dir, path string, archive archiveOptions, hash io.Writer) (string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if stat.IsDir() {
+		archivePath, remove, err := createArchive(context.Background(), path, archive)
+		if remove != nil {
+			defer remove()
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create archive: %w", err)
+		}
+		path = archivePath
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Join(o.Dir, dir), 0o700); err != nil {
+		return "", err
+	}
+
+	name := filepath.Base(path)
+	dst, err := os.OpenFile(filepath.Join(o.Dir, dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(io.MultiWriter(dst, hash), src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return name, err
+}
+
+// Items returns the queued messages, oldest first.
+func (o *Outbox) Items() ([]OutboxItem, error) {
+	entries, err := o.load()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]OutboxItem, len(entries))
+	for i, e := range entries {
+		items[i] = e.OutboxItem
+		items[i].Text = e.Message.Text
+		for _, f := range e.Message.Files {
+			items[i].Files = append(items[i].Files, filepath.Base(f))
+		}
+	}
+	return items, nil
+}
+
+// lock takes an exclusive lock on the outbox, waiting while another process or goroutine holds it,
+// so that a flush and the daemon's flush don't deliver the same message, and a message isn't added to an entry
+// that's being flushed. The returned function releases the lock.
+func (o *Outbox) lock() (unlock func(), err error) {
+	if err := os.MkdirAll(o.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox: %w", err)
+	}
+	unlock, err = lockFile(filepath.Join(o.Dir, ".lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	return unlock, nil
+}
+
+// load reads all entries, oldest first. A missing outbox is not an error, it's just empty.
+func (o *Outbox) load() ([]*outboxEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(o.Dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+
+	var entries []*outboxEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outbox: %w", err)
+		}
+
+		var e outboxEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", filepath.Base(path), err)
+		}
+		entries = append(entries, &e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Queued.Equal(entries[j].Queued) {
+			return entries[i].Queued.Before(entries[j].Queued)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// save writes an entry, replacing it atomically so that a concurrent flush never sees half of it.
+func (o *Outbox) save(e *outboxEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(o.Dir, ".entry_*")
+	if err != nil {
+		return fmt.Errorf("failed to create outbox entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write outbox entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(o.Dir, e.ID+".json")); err != nil {
+		return fmt.Errorf("failed to replace outbox entry: %w", err)
+	}
+	return nil
+}
+
+// remove deletes an entry and its file.
+func (o *Outbox) remove(e *outboxEntry) error {
+	if err := os.Remove(filepath.Join(o.Dir, e.ID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove outbox entry: %w", err)
+	}
+	return os.RemoveAll(filepath.Join(o.Dir, e.ID))
+}
+
+// message turns an entry back into a message that can be sent.
+func (o *Outbox) message(e *outboxEntry) (*Message, error) {
+	q := e.Message
+	msg := NewMessage(q.Text).WithFormat(q.Format).WithOverflow(q.Overflow)
+	msg.noUpload, msg.split, msg.encrypt, msg.code, msg.replyTo = q.NoUpload, q.Split, q.Encrypt, q.Code, q.ReplyTo
+	for _, b := range q.Buttons {
+		msg.WithButton(b.Label, b.Command)
+	}
+	if q.Options != nil {
+		msg.WithOptions(*q.Options)
+	}
+	msg.WithLevel(q.Level).WithTags(q.Tags...)
+
+	for _, f := range q.Files {
+		msg.WithFile(filepath.Join(o.Dir, e.ID, f))
+	}
+	if q.Type != "" {
+		typ, err := ParseMessageType(q.Type)
+		if err != nil {
+			return nil, err
+		}
+		msg.WithType(typ)
+	}
+	return msg, nil
+}
+
+func newOutboxID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate outbox ID: %w", err)
+	}
+	// IDs sort by the time they were made, which keeps the outbox readable when listed.
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
+
+// union returns the IDs in a, followed by those in b that aren't in a.
+func union(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, id := range b {
+		found := false
+		for _, have := range out {
+			found = found || have == id
+		}
+		if !found {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// Flush delivers the messages queued in an outbox, oldest first, and removes them once they're sent.
+// Messages older than the outbox's MaxAge are dropped, and so are recipients Telegram rejects the message for.
+// Flushing stops at the first message that can't be sent because of the network or the server, so that the
+// messages after it aren't delivered out of order.
+func (c *Client) Flush(ctx context.Context, o *Outbox) (FlushReport, error) {
+	var report FlushReport
+	if _, err := os.Stat(o.Dir); errors.Is(err, os.ErrNotExist) {
+		return report, nil // Nothing was ever queued
+	}
+
+	unlock, err := o.lock()
+	if err != nil {
+		return report, err
+	}
+	defer unlock()
+
+	entries, err := o.load()
+	if err != nil {
+		return report, err
+	}
+
+	var errs []error
+	for i, e := range entries {
+		if o.MaxAge > 0 && time.Since(e.Queued) > o.MaxAge {
+			if err := o.remove(e); err != nil {
+				errs = append(errs, err)
+			}
+			report.Expired++
+			continue
+		}
+
+		msg, err := o.message(e)
+		var results []SendResult
+		if err == nil {
+			results, err = c.send(ctx, msg, e.To)
+		}
+		if results == nil {
+			// The message itself is broken, like a file removed from the outbox, it will never be sent.
+			errs = append(errs, fmt.Errorf("%s: %w", e.ID, err))
+			if err := o.remove(e); err != nil {
+				errs = append(errs, err)
+			}
+			report.Failed++
+			continue
+		}
+
+		var pending []string
+		var lastErr error
+		failed := false
+		for _, r := range results {
+			if r.Err == nil {
+				continue
+			}
+			if _, temporary := retryDelay(r.Err); temporary || ctx.Err() != nil {
+				pending = append(pending, r.Recipient.ID)
+				lastErr = fmt.Errorf("%s: %s: %w", e.ID, r.Recipient.ID, r.Err)
+			} else {
+				failed = true
+				errs = append(errs, fmt.Errorf("%s: %s: %w", e.ID, r.Recipient.ID, r.Err))
+			}
+		}
+
+		if len(pending) > 0 {
+			// Only the recipients it wasn't delivered to get it again.
+			e.To = pending
+			e.Attempts++
+			e.LastError = lastErr.Error()
+			if err := o.save(e); err != nil {
+				errs = append(errs, err)
+			}
+			report.Pending = len(entries) - i
+			return report, errors.Join(append(errs, lastErr)...)
+		}
+
+		if err := o.remove(e); err != nil {
+			errs = append(errs, err)
+		}
+		if failed {
+			report.Failed++
+		} else {
+			report.Delivered++
+		}
+	}
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,163 @@
+package tell
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikolysz/tell/internal/fakebot"
+)
+
+// newQueueingClient creates a test client that queues messages it can't send, and doesn't retry them first.
+func newQueueingClient(t *testing.T) (*Client, *fakebot.Server) {
+	t.Helper()
+
+	client, api := newTestClient(t)
+	client.Retries = 0
+	client.Outbox = NewOutbox(filepath.Join(t.TempDir(), "outbox"))
+	return client, api
+}
+
+// telegramDown makes the next call to method fail as if Telegram couldn't be reached.
+func telegramDown(api *fakebot.Server, method string) {
+	api.Fail(method, 502, "Bad Gateway")
+}
+
+func TestOutboxQueue(t *testing.T) {
+	client, api := newQueueingClient(t)
+	outbox := client.Outbox
+
+	// The queued copy of the file is sent, even if the original changes in the meantime.
+	file := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(file, []byte("all good"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		telegramDown(api, "sendDocument")
+		results, err := client.Send(context.Background(), NewMessage("Report").WithFile(file), "alice")
+		if err != nil {
+			t.Fatalf("expected the message to be queued, got %s", err)
+		}
+		if !results[0].Queued || results[0].Err == nil {
+			t.Errorf("expected the result to say the message was queued, got %+v", results[0])
+		}
+	}
+
+	items, err := outbox.Items()
+	if err != nil {
+		t.Fatalf("failed to list outbox: %s", err)
+	}
+	if len(items) != 1 || items[0].Text != "Report" || items[0].Files[0] != "report.txt" || items[0].To[0] != "alice" {
+		t.Fatalf("expected the message to be queued once, got %+v", items)
+	}
+
+	if err := os.WriteFile(file, []byte("all bad"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := client.Flush(context.Background(), outbox)
+	if err != nil {
+		t.Fatalf("failed to flush outbox: %s", err)
+	}
+	if report.Delivered != 1 {
+		t.Errorf("expected 1 message to be delivered, got %+v", report)
+	}
+	docs := api.Calls("sendDocument")
+	if doc := docs[len(docs)-1].Files["document"]; doc.Name != "report.txt" || string(doc.Data) != "all good" {
+		t.Errorf("expected the queued copy of the file to be sent, got %s: %q", doc.Name, doc.Data)
+	}
+
+	results, err := client.Send(context.Background(), NewMessage("Hello"), "carol")
+	if err == nil || results != nil {
+		t.Errorf("invalid messages shouldn't be queued, got %v, %v", results, err)
+	}
+	if items, _ := outbox.Items(); len(items) != 0 {
+		t.Errorf("expected the outbox to be empty, got %+v", items)
+	}
+}
+
+func TestOutboxFlush(t *testing.T) {
+	client, api := newQueueingClient(t)
+	outbox := client.Outbox
+
+	for _, text := range []string{"first", "second"} {
+		telegramDown(api, "sendMessage")
+		if _, err := client.Send(context.Background(), NewMessage(text), "alice"); err != nil {
+			t.Fatalf("expected the message to be queued, got %s", err)
+		}
+	}
+
+	// Telegram is still down, so nothing is sent after the first message fails.
+	telegramDown(api, "sendMessage")
+	calls := len(api.Calls("sendMessage"))
+	report, err := client.Flush(context.Background(), outbox)
+	if err == nil || report.Pending != 2 {
+		t.Errorf("expected both messages to be pending, got %+v, %v", report, err)
+	}
+
+	items, _ := outbox.Items()
+	if len(items) != 2 || items[0].Attempts != 1 || items[1].Attempts != 0 || items[0].LastError == "" {
+		t.Errorf("expected the failed attempt to be recorded on the first message only, got %+v", items)
+	}
+
+	report, err = client.Flush(context.Background(), outbox)
+	if err != nil || report.Delivered != 2 {
+		t.Fatalf("expected both messages to be delivered, got %+v, %v", report, err)
+	}
+	sent := api.Calls("sendMessage")[calls:]
+	if len(sent) != 3 || sent[1].Params["text"] != "first" || sent[2].Params["text"] != "second" {
+		t.Errorf("expected a failed call followed by both messages in order, got %v", sent)
+	}
+}
+
+func TestOutboxConcurrentFlush(t *testing.T) {
+	client, api := newQueueingClient(t)
+
+	telegramDown(api, "sendMessage")
+	if _, err := client.Send(context.Background(), NewMessage("Disk full"), "alice"); err != nil {
+		t.Fatalf("expected the message to be queued, got %s", err)
+	}
+	before := len(api.Calls("sendMessage"))
+
+	// A manual flush and the daemon's use separate outboxes for the same directory.
+	api.Delay("sendMessage", 200*time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Flush(context.Background(), NewOutbox(client.Outbox.Dir)); err != nil {
+				t.Errorf("failed to flush outbox: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sent := len(api.Calls("sendMessage")) - before; sent != 1 {
+		t.Errorf("expected the message to be delivered once, got %d", sent)
+	}
+}
+
+func TestOutboxMaxAge(t *testing.T) {
+	client, api := newQueueingClient(t)
+	outbox := client.Outbox
+
+	telegramDown(api, "sendMessage")
+	if _, err := client.Send(context.Background(), NewMessage("Disk full"), "alice"); err != nil {
+		t.Fatalf("expected the message to be queued, got %s", err)
+	}
+
+	outbox.MaxAge = time.Nanosecond
+	report, err := client.Flush(context.Background(), outbox)
+	if err != nil || report.Expired != 1 || report.Delivered != 0 {
+		t.Errorf("expected the message to expire, got %+v, %v", report, err)
+	}
+	// Only the lock file is left.
+	if entries, _ := os.ReadDir(outbox.Dir); len(entries) != 1 || entries[0].Name() != ".lock" {
+		t.Errorf("expected the outbox to be empty, got %v", entries)
+	}
+}
@@ -0,0 +1,68 @@
+package tell
+
+import "github.com/PaulSonOfLars/gotgbot/v2"
+
+// sentType returns what the message was sent as, after any fallbacks. Files that were uploaded are FileUploadMessage,
+// even though Telegram only got a link to them.
+func (msg *Message) sentType() MessageType {
+	if msg.uploadURL != "" {
+		return FileUploadMessage
+	}
+	return msg.typ
+}
+
+// uploadURLs returns where the message's files were uploaded, including those of an album's parts.
+func (msg *Message) uploadURLs() []string {
+	var urls []string
+	if msg.uploadURL != "" {
+		urls = append(urls, msg.uploadURL)
+	}
+	for _, part := range msg.parts {
+		urls = append(urls, part.uploadURLs()...)
+	}
+	return urls
+}
+
+// sentFileIDs returns Telegram's IDs for the files sent since the last call to resetSent,
+// including those of an album's parts.
+func (msg *Message) sentFileIDs() []string {
+	ids := append([]string(nil), msg.fileIDs...)
+	for _, part := range msg.parts {
+		ids = append(ids, part.sentFileIDs()...)
+	}
+	return ids
+}
+
+// resetSent forgets the files sent so far, before the message is sent to another recipient.
+func (msg *Message) resetSent() {
+	msg.fileIDs = nil
+	for _, part := range msg.parts {
+		part.resetSent()
+	}
+}
+
+// recordSent remembers the ID of the file in a message that was sent, if it has one.
+func (msg *Message) recordSent(m *gotgbot.Message) {
+	var id string
+	switch {
+	case len(m.Photo) > 0:
+		id = m.Photo[len(m.Photo)-1].FileId // The largest size comes last
+	case m.Animation != nil:
+		id = m.Animation.FileId
+	case m.Audio != nil:
+		id = m.Audio.FileId
+	case m.Video != nil:
+		id = m.Video.FileId
+	case m.VideoNote != nil:
+		id = m.VideoNote.FileId
+	case m.Voice != nil:
+		id = m.Voice.FileId
+	case m.Sticker != nil:
+		id = m.Sticker.FileId
+	case m.Document != nil:
+		id = m.Document.FileId
+	}
+	if id != "" {
+		msg.fileIDs = append(msg.fileIDs, id)
+	}
+}
@@ -0,0 +1,158 @@
+package tell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+const (
+	// DefaultTimeout is how long a single request may take, unless the client is told otherwise.
+	DefaultTimeout = 10 * time.Minute
+	// DefaultRetries is how many times failed requests are retried, unless the client is told otherwise.
+	DefaultRetries = 3
+)
+
+// How long to wait before the first retry, doubled for each retry after it. A variable so that tests can shorten it.
+var retryBackoff = time.Second
+
+// The longest time to wait between retries, unless Telegram asks for longer.
+const maxRetryBackoff = time.Minute
+
+// retryPolicy decides how long requests may take, and how often they're retried when they fail.
+type retryPolicy struct {
+	retries int
+	timeout time.Duration // Zero means no limit
+}
+
+// request calls a Bot API method, and returns its result.
+// Failed requests are retried, sending the files in data again from the start.
+func (p retryPolicy) request(ctx context.Context, bot *gotgbot.Bot, method string, params map[string]string, data map[string]gotgbot.NamedReader) (json.RawMessage, error) {
+	var res json.RawMessage
+	err := p.do(ctx, func(ctx context.Context) error {
+		if err := rewind(data); err != nil {
+			return err
+		}
+		var err error
+		res, err = bot.RequestWithContext(ctx, method, params, data, nil)
+		return err
+	})
+	return res, err
+}
+
+// do calls f until it succeeds, fails with an error that isn't worth retrying, or runs out of retries.
+// Each call gets its own timeout. Waiting between calls stops as soon as ctx is done.
+func (p retryPolicy) do(ctx context.Context, f func(ctx context.Context) error) error {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := p.attempt(ctx, f)
+		if err == nil {
+			return nil
+		}
+
+		wait, ok := retryDelay(err)
+		if !ok || attempt >= p.retries || ctx.Err() != nil {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+			if backoff *= 2; backoff > maxRetryBackoff {
+				backoff = maxRetryBackoff
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (p retryPolicy) attempt(ctx context.Context, f func(ctx context.Context) error) error {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	return f(ctx)
+}
+
+// Telegram only says how long to wait after a 429 in the description, gotgbot drops the retry_after parameter.
+var retryAfterPattern = regexp.MustCompile(`retry after (\d+)`)
+
+// retryDelay reports whether a failed request is worth retrying, and how long the server asked us to wait first.
+// A zero delay means the server didn't ask for anything, and the policy's backoff should be used.
+// Problems with the network and the server are retried, problems with the request itself aren't.
+func retryDelay(err error) (time.Duration, bool) {
+	var tgErr *gotgbot.TelegramError
+	if errors.As(err, &tgErr) {
+		if tgErr.Code == http.StatusTooManyRequests {
+			var wait time.Duration
+			if m := retryAfterPattern.FindStringSubmatch(tgErr.Description); m != nil {
+				seconds, _ := strconv.Atoi(m[1])
+				wait = time.Duration(seconds) * time.Second
+			}
+			return wait, true
+		}
+		return 0, tgErr.Code >= 500
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return 0, statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+	}
+
+	// The attempt's own timeout expired, the caller's context is checked separately.
+	if errors.Is(err, context.DeadlineExceeded) {
+		return 0, true
+	}
+	if errors.Is(err, context.Canceled) {
+		return 0, false
+	}
+
+	var netErr net.Error
+	return 0, errors.As(err, &netErr)
+}
+
+// statusError is an HTTP response with an unexpected status code.
+type statusError struct {
+	host   string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.host, e.status)
+}
+
+// statusTransport turns Bot API responses that aren't JSON, like the error page of a proxy in front of the server,
+// into statusErrors. gotgbot would fail to decode them, and the error couldn't be told apart from a bad request.
+type statusTransport struct {
+	http.RoundTripper
+}
+
+func (t statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode >= 400 && mediaType != "application/json" {
+		resp.Body.Close()
+		return nil, &statusError{host: req.URL.Host, status: resp.Status, code: resp.StatusCode}
+	}
+	return resp, nil
+}
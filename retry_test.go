@@ -0,0 +1,143 @@
+package tell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"github.com/mikolysz/tell/internal/fakebot"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		err   error
+		wait  time.Duration
+		retry bool
+	}{
+		{&gotgbot.TelegramError{Code: 429, Description: "Too Many Requests: retry after 35"}, 35 * time.Second, true},
+		{&gotgbot.TelegramError{Code: 429, Description: "Too Many Requests"}, 0, true},
+		{&gotgbot.TelegramError{Code: 502, Description: "Bad Gateway"}, 0, true},
+		{&gotgbot.TelegramError{Code: 400, Description: "Bad Request: chat not found"}, 0, false},
+		{fmt.Errorf("failed to upload file: %w", &statusError{code: 503}), 0, true},
+		{fmt.Errorf("failed to upload file: %w", &statusError{code: 413}), 0, false},
+		{fmt.Errorf("failed to send: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), 0, true},
+		{fmt.Errorf("failed to send: %w", context.DeadlineExceeded), 0, true},
+		{fmt.Errorf("failed to send: %w", context.Canceled), 0, false},
+		{errors.New("failed to open file"), 0, false},
+	}
+
+	for _, c := range cases {
+		wait, retry := retryDelay(c.err)
+		if wait != c.wait || retry != c.retry {
+			t.Errorf("wrong decision for %q, expected %s, %t, got %s, %t", c.err, c.wait, c.retry, wait, retry)
+		}
+	}
+}
+
+// fastRetries makes retries wait a millisecond instead of seconds, until the test ends.
+func fastRetries(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = backoff })
+}
+
+func TestSendRetries(t *testing.T) {
+	fastRetries(t)
+	client, api := newTestClient(t)
+
+	api.Fail("sendMessage", 500, "Internal Server Error")
+	api.Fail("sendMessage", 429, "Too Many Requests: retry after 0")
+	if _, err := client.Send(context.Background(), NewMessage("Hello"), "alice"); err != nil {
+		t.Fatalf("sending failed despite retries: %s", err)
+	}
+	if calls := api.Calls("sendMessage"); len(calls) != 3 {
+		t.Errorf("expected 2 failed calls and a successful one, got %d calls", len(calls))
+	}
+
+	api.Fail("sendMessage", 400, "Bad Request: chat not found")
+	if _, err := client.Send(context.Background(), NewMessage("Hello"), "alice"); err == nil {
+		t.Errorf("sending succeeded even though the request was bad")
+	}
+	if calls := api.Calls("sendMessage"); len(calls) != 4 {
+		t.Errorf("bad requests shouldn't be retried, got %d calls", len(calls)-3)
+	}
+
+	client.Retries = 1
+	api.Fail("sendMessage", 500, "Internal Server Error")
+	api.Fail("sendMessage", 500, "Internal Server Error")
+	if _, err := client.Send(context.Background(), NewMessage("Hello"), "alice"); err == nil {
+		t.Errorf("sending succeeded even though it failed more often than retried")
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	fastRetries(t)
+	client, api := newTestClient(t)
+	client.Timeout = 100 * time.Millisecond
+
+	// The file has to be sent again in full when the first request times out.
+	api.Delay("sendDocument", time.Minute)
+	if _, err := client.Send(context.Background(), NewMessage("").WithFile("testdata/foo"), "alice"); err != nil {
+		t.Fatalf("sending failed despite retries: %s", err)
+	}
+
+	want, err := os.ReadFile("testdata/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := api.Calls("sendDocument")
+	if len(calls) != 1 || string(calls[0].Files["document"].Data) != string(want) {
+		t.Errorf("expected the whole file to be sent by the retry, got %v", calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Send(ctx, NewMessage("Hello"), "alice"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected sending to be cancelled, got %v", err)
+	}
+}
+
+func TestUploadRetries(t *testing.T) {
+	fastRetries(t)
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "https://files.example.com/foo")
+	}))
+	defer server.Close()
+
+	api := fakebot.New(t)
+	client, err := NewClient(&Config{
+		BotToken:      fakebot.Token,
+		APIURL:        api.URL,
+		Recipients:    map[string]int64{"alice": 1},
+		Upload:        UploadConfig{URL: server.URL},
+		FileSizeLimit: 1, // Upload everything
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+
+	if _, err := client.Send(context.Background(), NewMessage("").WithFile("testdata/foo")); err != nil {
+		t.Fatalf("sending failed despite retries: %s", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected the upload to be retried once, got %d attempts", attempts)
+	}
+	if calls := api.Calls("sendMessage"); len(calls) != 1 || calls[0].Params["text"] != "https://files.example.com/foo" {
+		t.Errorf("expected the link to be sent, got %v", calls)
+	}
+}
@@ -0,0 +1,122 @@
+package tell
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Level is how severe a message is. Routing rules use it to decide who gets the message.
+type Level string
+
+const (
+	LevelInfo     Level = "info"
+	LevelWarn     Level = "warn"
+	LevelError    Level = "error"
+	LevelCritical Level = "critical"
+)
+
+// levelRanks orders the levels from least to most severe. Messages without a level rank below all of them.
+var levelRanks = map[Level]int{LevelInfo: 1, LevelWarn: 2, LevelError: 3, LevelCritical: 4}
+
+// ParseLevel checks that level is one of info, warn, error or critical.
+func ParseLevel(level string) (Level, error) {
+	if _, ok := levelRanks[Level(level)]; !ok {
+		return "", fmt.Errorf("Invalid level: %s", level)
+	}
+	return Level(level), nil
+}
+
+// Route is a routing rule from the config. It applies to messages that meet all of its conditions,
+// and a rule without conditions applies to every message. The first rule that applies decides.
+type Route struct {
+	Name  string `json:"name,omitempty"`  // Shown by 'tell --explain', the rule's position if empty
+	Level Level  `json:"level,omitempty"` // The least severe level of the messages the rule applies to
+	Tag   string `json:"tag,omitempty"`   // A tag the message must have
+	Match string `json:"match,omitempty"` // A regular expression the text must match
+
+	To     []string `json:"to,omitempty"`     // Who gets the message instead of all authorized users
+	Silent *bool    `json:"silent,omitempty"` // Whether the message is sent silently, instead of the default
+}
+
+// WithLevel sets how severe the message is, for the routing rules in the config.
+func (msg *Message) WithLevel(level Level) *Message {
+	msg.level = level
+	return msg
+}
+
+// WithTags tags the message, for the routing rules in the config.
+func (msg *Message) WithTags(tags ...string) *Message {
+	msg.tags = append(msg.tags, tags...)
+	return msg
+}
+
+// Route returns the index of the routing rule that applies to the message, or -1 if none does.
+func (c *Config) Route(msg *Message) (int, error) {
+	if c == nil {
+		return -1, nil
+	}
+	for i, r := range c.Routes {
+		ok, err := r.matches(msg)
+		if err != nil {
+			return -1, fmt.Errorf("route %s: %w", r.DisplayName(i), err)
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// DisplayName returns the rule's name, or its position in the config if it doesn't have one.
+func (r *Route) DisplayName(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// Options returns the send options for a message the rule applies to, given the defaults it would have otherwise.
+func (r *Route) Options(defaults SendOptions) SendOptions {
+	if r.Silent != nil {
+		defaults.Silent = *r.Silent
+	}
+	return defaults
+}
+
+// matches reports whether the rule applies to the message.
+func (r *Route) matches(msg *Message) (bool, error) {
+	if r.Level != "" {
+		rank, ok := levelRanks[r.Level]
+		if !ok {
+			return false, fmt.Errorf("invalid level %q", r.Level)
+		}
+		if levelRanks[msg.level] < rank {
+			return false, nil
+		}
+	}
+
+	if r.Tag != "" && !contains(msg.tags, r.Tag) {
+		return false, nil
+	}
+
+	if r.Match != "" {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern: %w", err)
+		}
+		if !re.MatchString(msg.text) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// contains reports whether s is one of list.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
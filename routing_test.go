@@ -0,0 +1,70 @@
+package tell
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	loud, quiet := false, true
+	cfg := &Config{Routes: []Route{
+		{Name: "pager", Level: LevelError, Tag: "prod", Silent: &loud},
+		{Level: LevelError},
+		{Match: `(?i)backup`, Silent: &quiet},
+	}}
+
+	for _, tt := range []struct {
+		msg  *Message
+		want int
+	}{
+		{NewMessage("Disk full").WithLevel(LevelCritical).WithTags("db", "prod"), 0},
+		{NewMessage("Disk full").WithLevel(LevelError).WithTags("staging"), 1},
+		{NewMessage("Disk full").WithLevel(LevelWarn).WithTags("prod"), -1},
+		{NewMessage("Nightly backup done"), 2},
+		{NewMessage("Nightly backup failed").WithLevel(LevelError), 1},
+	} {
+		got, err := cfg.Route(tt.msg)
+		if err != nil {
+			t.Fatalf("failed to route %q: %s", tt.msg.text, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q at level %q with tags %v: expected route %d, got %d", tt.msg.text, tt.msg.level, tt.msg.tags, tt.want, got)
+		}
+	}
+
+	cfg.Routes = []Route{{Match: "("}}
+	if _, err := cfg.Route(NewMessage("Hi")); err == nil {
+		t.Errorf("an invalid pattern was accepted")
+	}
+}
+
+func TestSendRouted(t *testing.T) {
+	client, api := newTestClient(t)
+	quiet := true
+	client.cfg.Routes = []Route{
+		{Level: LevelError, To: []string{"bob"}},
+		{Tag: "report", To: []string{"alice"}, Silent: &quiet},
+	}
+
+	ctx := context.Background()
+	if _, err := client.Send(ctx, NewMessage("Build failed").WithLevel(LevelCritical)); err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+	if _, err := client.Send(ctx, NewMessage("Nightly report").WithTags("report")); err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+	// Recipients and options given with the message take precedence.
+	if _, err := client.Send(ctx, NewMessage("Nightly report").WithTags("report").WithOptions(SendOptions{}), "bob"); err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+
+	sent := api.Calls("sendMessage")
+	if len(sent) != 3 {
+		t.Fatalf("expected each message to go to a single recipient, got %v", sent)
+	}
+	for i, want := range []struct{ chat, silent string }{{"2", ""}, {"1", "true"}, {"2", ""}} {
+		if p := sent[i].Params; p["chat_id"] != want.chat || p["disable_notification"] != want.silent {
+			t.Errorf("message %d: expected chat %s and silent %q, got %v", i+1, want.chat, want.silent, p)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package tell
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Registers the JPEG decoder used to check photo dimensions
+	_ "image/png"  // Registers the PNG decoder used to check photo dimensions
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// Telegram rejects photos whose width and height add up to more than this, or that are too long and narrow.
+	photoDimensionLimit = 10000
+	photoRatioLimit     = 20
+
+	// sniffLen is how much of a file is read to recognise its contents, the same as http.DetectContentType uses.
+	sniffLen = 512
+)
+
+// contentTypes maps the content types http.DetectContentType recognises to the type of message to send them as.
+var contentTypes = map[string]MessageType{
+	"image/jpeg": PhotoMessage,
+	"image/png":  PhotoMessage,
+	"image/gif":  AnimationMessage,
+	"image/webp": StickerMessage,
+	"video/mp4":  VideoMessage,
+	"audio/mpeg": AudioMessage,
+}
+
+// sniffFileType recognises what a file contains from its first bytes.
+// It returns the type of message to send it as, and the content type found. If the contents aren't recognised,
+// known is false, and the type has to be guessed from the file's name instead.
+func sniffFileType(path string) (typ MessageType, contentType string, known bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, "", false, fmt.Errorf("failed to read file: %w", err)
+	}
+	head = head[:n]
+
+	// http.DetectContentType reports M4A files as MP4 videos, and doesn't look inside Ogg files.
+	if len(head) >= 12 && string(head[4:12]) == "ftypM4A " {
+		return AudioMessage, "audio/mp4", true, nil
+	}
+
+	contentType, _, _ = strings.Cut(http.DetectContentType(head), ";")
+	switch contentType {
+	case "application/octet-stream":
+		return 0, contentType, false, nil
+	case "application/ogg":
+		// Only Opus is played as a voice message, other Ogg files are left to their extension.
+		if bytes.Contains(head, []byte("OpusHead")) {
+			return VoiceMessage, "audio/ogg", true, nil
+		}
+		return 0, contentType, false, nil
+	}
+
+	typ, ok := contentTypes[contentType]
+	if !ok {
+		typ = DocumentMessage
+	}
+	return typ, contentType, true, nil
+}
+
+// checkPhoto reports why Telegram wouldn't accept an image as a photo, or "" if it would.
+func checkPhoto(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return "isn't a valid image", nil
+	}
+
+	w, h := cfg.Width, cfg.Height
+	switch {
+	case w == 0 || h == 0:
+		return "is an empty image", nil
+	case w+h > photoDimensionLimit:
+		return fmt.Sprintf("is %dx%d pixels, but Telegram only accepts photos up to %d pixels wide and high together", w, h, photoDimensionLimit), nil
+	case w > h*photoRatioLimit || h > w*photoRatioLimit:
+		return fmt.Sprintf("is %dx%d pixels, but Telegram only accepts photos up to %d times longer than they're wide", w, h, photoRatioLimit), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,82 @@
+package tell
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngFile writes a blank PNG image of the given size to a file called name in dir.
+func pngFile(t *testing.T, dir, name string, width, height int) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode image: %s", err)
+	}
+	return path
+}
+
+func TestDetectFileType(t *testing.T) {
+	dir := t.TempDir()
+
+	text := filepath.Join(dir, "notes.jpg")
+	if err := os.WriteFile(text, []byte("This is not a photo."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opus := filepath.Join(dir, "recording")
+	if err := os.WriteFile(opus, []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00OpusHead"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	garbage, cleanup, err := tempFile("jpg", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	cases := []struct {
+		path   string
+		want   MessageType
+		reason string // A part of the reason the file is sent as a document, if it is
+	}{
+		{pngFile(t, dir, "screenshot.dat", 640, 480), PhotoMessage, ""},
+		{pngFile(t, dir, "banner.png", 3000, 100), DocumentMessage, "20 times"},
+		{pngFile(t, dir, "poster.png", 5001, 5000), DocumentMessage, "10000 pixels"},
+		{pngFile(t, dir, "document.pdf", 10, 10), PhotoMessage, ""},
+		{text, DocumentMessage, "contains text/plain"},
+		{opus, VoiceMessage, ""},
+		{garbage, PhotoMessage, ""}, // unrecognised contents are trusted to match the extension
+		{"testdata/foo", DocumentMessage, ""},
+	}
+
+	for _, c := range cases {
+		typ, reason, err := detectFileType(c.path, SizeLimits{})
+		if err != nil {
+			t.Errorf("%s: detection failed: %s", c.path, err)
+			continue
+		}
+		if typ != c.want {
+			t.Errorf("%s: wrong type, expected %s, got %s", c.path, c.want, typ)
+		}
+		if (c.reason == "") != (reason == "") || !strings.Contains(reason, c.reason) {
+			t.Errorf("%s: wrong reason, expected it to mention %q, got %q", c.path, c.reason, reason)
+		}
+	}
+
+	msg := NewMessage("").WithFile(pngFile(t, dir, "a.png", 10, 10)).WithFile(filepath.Join(dir, "banner.png"))
+	if err := msg.Check(SizeLimits{}); err != nil {
+		t.Fatalf("check failed: %s", err)
+	}
+	if warnings := msg.Warnings(); len(warnings) != 1 || !strings.HasPrefix(warnings[0], filepath.Join(dir, "banner.png")) {
+		t.Errorf("expected a warning about the banner, got %q", warnings)
+	}
+}
@@ -0,0 +1,265 @@
+package tell
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+const (
+	// Telegram accepts documents of up to 50MB, but bots can only download files of up to 20MB.
+	// Parts are kept small enough for 'tell -r' to be able to join them again.
+	splitPartSize = 20 * 1024 * 1024
+
+	// splitManifestFormat identifies manifests written by tell, in case other JSON files end in manifestSuffix.
+	splitManifestFormat = "tell-split-1"
+	manifestSuffix      = ".parts.json"
+)
+
+// splitManifest describes a file that was split into parts, so that it can be joined again.
+// It is sent as a document after all the parts.
+type splitManifest struct {
+	Format string     `json:"format"`
+	Name   string     `json:"name"`
+	Size   int64      `json:"size"`
+	SHA256 string     `json:"sha256"`
+	Parts  []filePart `json:"parts"`
+}
+
+type filePart struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	FileID string `json:"file_id"` // Telegram's ID for the part, filled in once the part has been sent
+
+	path string // Where the part is stored locally while sending
+}
+
+// splitFile cuts a file into numbered parts of at most partSize bytes, stored in a temporary directory.
+// The returned remove function, if non-nil, deletes the parts.
+func splitFile(path string, partSize int64) (manifest *splitManifest, remove func(), err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	tmpdir, err := os.MkdirTemp("", "tell")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	remove = func() {
+		os.RemoveAll(tmpdir)
+	}
+
+	name := filepath.Base(path)
+	manifest = &splitManifest{
+		Format: splitManifestFormat,
+		Name:   name,
+	}
+
+	total := sha256.New()
+	for i := 1; ; i++ {
+		part := filePart{Name: fmt.Sprintf("%s.%03d", name, i)}
+		part.path = filepath.Join(tmpdir, part.Name)
+
+		dst, err := os.Create(part.path)
+		if err != nil {
+			return nil, remove, fmt.Errorf("failed to create part: %w", err)
+		}
+
+		hash := sha256.New()
+		n, err := io.CopyN(io.MultiWriter(dst, hash, total), src, partSize)
+		if closeErr := dst.Close(); closeErr != nil && (err == nil || err == io.EOF) {
+			err = closeErr
+		}
+
+		if err != nil && err != io.EOF {
+			return nil, remove, fmt.Errorf("failed to write part: %w", err)
+		}
+
+		// A file whose size is a multiple of partSize would otherwise end in an empty part.
+		if n == 0 && i > 1 {
+			break
+		}
+
+		part.Size = n
+		part.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		manifest.Parts = append(manifest.Parts, part)
+		manifest.Size += n
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	manifest.SHA256 = hex.EncodeToString(total.Sum(nil))
+	return manifest, remove, nil
+}
+
+// sendParts sends the parts of a split file as documents, followed by the manifest, and returns the IDs of the messages sent.
+// Parts are uploaded only once, sending them to further chats reuses their file IDs.
+func (msg *Message) sendParts(ctx context.Context, bot *gotgbot.Bot, chatID int64) ([]int64, error) {
+	manifest := msg.manifest
+	var ids []int64
+
+	for i := range manifest.Parts {
+		part := &manifest.Parts[i]
+		params := map[string]string{
+			"chat_id": fmt.Sprint(chatID),
+			"caption": fmt.Sprintf("%s, part %d of %d", manifest.Name, i+1, len(manifest.Parts)),
+		}
+		var data map[string]gotgbot.NamedReader
+
+		if part.FileID != "" {
+			params["document"] = part.FileID
+		} else {
+			f, err := os.Open(part.path)
+			if err != nil {
+				return ids, fmt.Errorf("failed to open part: %w", err)
+			}
+			defer f.Close()
+			params["document"] = "attach://document"
+			data = map[string]gotgbot.NamedReader{
+				"document": f,
+			}
+		}
+
+		sent, err := msg.sendRequest(ctx, bot, "sendDocument", params, data)
+		if err != nil {
+			return ids, fmt.Errorf("failed to send part %d: %w", i+1, err)
+		}
+		if sent.Document == nil {
+			return ids, fmt.Errorf("unexpected response when sending part %d", i+1)
+		}
+		part.FileID = sent.Document.FileId
+		ids = append(ids, sent.MessageId)
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return ids, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	params := map[string]string{
+		"chat_id":  fmt.Sprint(chatID),
+		"document": "attach://document",
+	}
+
+	caption, format := msg.render()
+	if utf16Len(caption) > captionLimit {
+		caption, format = preview(msg.text), PlainText
+	}
+	params["caption"] = caption
+	if format != PlainText {
+		params["parse_mode"] = string(format)
+	}
+	markup, err := msg.replyMarkup()
+	if err != nil {
+		return ids, err
+	}
+	if markup != "" {
+		params["reply_markup"] = markup
+	}
+
+	data := map[string]gotgbot.NamedReader{
+		"document": gotgbot.NamedFile{
+			File:     bytes.NewReader(manifestJSON),
+			FileName: manifest.Name + manifestSuffix,
+		},
+	}
+
+	sent, err := msg.sendRequest(ctx, bot, "sendDocument", params, data)
+	if err != nil {
+		return ids, fmt.Errorf("failed to send manifest: %w", err)
+	}
+	return append(ids, sent.MessageId), nil
+}
+
+// readManifest reads the manifest of a split file.
+// It returns nil if the file isn't a manifest written by tell.
+func readManifest(path string) (*splitManifest, error) {
+	if !strings.HasSuffix(path, manifestSuffix) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest splitManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Format != splitManifestFormat {
+		return nil, nil
+	}
+	return &manifest, nil
+}
+
+// joinParts downloads the parts of a split file and joins them in the current directory, verifying their checksums.
+// It returns the path of the joined file.
+func (c *Client) joinParts(ctx context.Context, manifest *splitManifest) (string, error) {
+	name := fileName(manifest.Name, "document", "joined", "")
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("file %s already exists", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+
+	err = c.writeParts(ctx, f, manifest)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+func (c *Client) writeParts(ctx context.Context, w io.Writer, manifest *splitManifest) error {
+	total := sha256.New()
+	var size int64
+
+	for i, part := range manifest.Parts {
+		if c.OnPartDownload != nil {
+			c.OnPartDownload(i+1, len(manifest.Parts))
+		}
+
+		body, err := c.openFile(ctx, part.FileID)
+		if err != nil {
+			return fmt.Errorf("failed to download part %d: %w", i+1, err)
+		}
+
+		hash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(w, hash, total), body)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to download part %d: %w", i+1, err)
+		}
+
+		if n != part.Size || hex.EncodeToString(hash.Sum(nil)) != part.SHA256 {
+			return fmt.Errorf("part %d is corrupted, its checksum doesn't match the manifest", i+1)
+		}
+		size += n
+	}
+
+	if size != manifest.Size || hex.EncodeToString(total.Sum(nil)) != manifest.SHA256 {
+		return fmt.Errorf("joined file is corrupted, its checksum doesn't match the manifest")
+	}
+	return nil
+}
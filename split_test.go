@@ -0,0 +1,86 @@
+package tell
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestSplitFile(t *testing.T) {
+	for _, size := range []int{0, 10, 30, 31} {
+		file, cleanup, err := tempFile("bin", size)
+		if err != nil {
+			t.Fatalf("failed to create file: %s", err)
+		}
+		defer cleanup()
+
+		manifest, remove, err := splitFile(file, 10)
+		if remove != nil {
+			defer remove()
+		}
+		if err != nil {
+			t.Fatalf("failed to split %d byte file: %s", size, err)
+		}
+
+		wantParts := (size + 9) / 10
+		if wantParts == 0 {
+			wantParts = 1
+		}
+		if len(manifest.Parts) != wantParts {
+			t.Errorf("wrong number of parts for %d byte file, expected %d, got %d", size, wantParts, len(manifest.Parts))
+		}
+
+		var joined []byte
+		for _, part := range manifest.Parts {
+			data, err := os.ReadFile(part.path)
+			if err != nil {
+				t.Fatalf("failed to read part: %s", err)
+			}
+			if int64(len(data)) != part.Size || len(data) > 10 {
+				t.Errorf("wrong part size, expected %d and at most 10, got %d", part.Size, len(data))
+			}
+			joined = append(joined, data...)
+		}
+
+		original, _ := os.ReadFile(file)
+		if !bytes.Equal(joined, original) || manifest.Size != int64(size) {
+			t.Errorf("joined parts differ from the %d byte original", size)
+		}
+	}
+}
+
+func TestWriteParts(t *testing.T) {
+	client, api := newTestClient(t)
+
+	manifest := &splitManifest{Format: splitManifestFormat, Name: "backup.tar"}
+	var whole []byte
+	for i, data := range []string{"first part ", "second part"} {
+		id := fmt.Sprintf("part%d", i)
+		api.AddFile(id, id, []byte(data))
+		sum := sha256.Sum256([]byte(data))
+		manifest.Parts = append(manifest.Parts, filePart{Name: id, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:]), FileID: id})
+		whole = append(whole, data...)
+	}
+	sum := sha256.Sum256(whole)
+	manifest.Size, manifest.SHA256 = int64(len(whole)), hex.EncodeToString(sum[:])
+
+	var progress []string
+	client.OnPartDownload = func(part, parts int) {
+		progress = append(progress, fmt.Sprintf("%d/%d", part, parts))
+	}
+
+	var buf bytes.Buffer
+	if err := client.writeParts(context.Background(), &buf, manifest); err != nil {
+		t.Fatalf("failed to join parts: %s", err)
+	}
+	if buf.String() != string(whole) {
+		t.Errorf("wrong joined file, got %q", buf.String())
+	}
+	if fmt.Sprint(progress) != "[1/2 2/2]" {
+		t.Errorf("expected progress to be reported for each part, got %v", progress)
+	}
+}
@@ -0,0 +1,111 @@
+package tell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// checkReader decides how data attached with WithReader is sent. Its size isn't known until it's read,
+// so it's assumed to fit in a message until prepare finds out otherwise.
+func (msg *Message) checkReader() error {
+	if len(msg.files) > 0 {
+		return fmt.Errorf("a reader can't be attached together with files")
+	}
+	if msg.typeSet && msg.requested == FileUploadMessage && (msg.noUpload || msg.split) {
+		return fmt.Errorf("files of type upload can't be sent without uploading them")
+	}
+
+	msg.typ = DocumentMessage
+	if msg.typeSet {
+		msg.typ = msg.requested
+	}
+	if msg.encrypt && msg.typ != DocumentMessage && msg.typ != FileUploadMessage {
+		return fmt.Errorf("encrypted files can't be sent with message type %s", msg.typ)
+	}
+	if info, ok := typeInfo[msg.typ]; ok && info.file == "" {
+		return fmt.Errorf("sending files is not supported with message type %s", msg.typ)
+	}
+	if info, ok := typeInfo[msg.typ]; ok && info.text == "" && msg.text != "" {
+		return fmt.Errorf("sending text is not supported with message type %s", msg.typ)
+	}
+	return nil
+}
+
+// spoolReader reads the attached reader into a temporary file, so that it can be sent to several recipients and
+// retried. Once there's more than Telegram accepts, the rest is streamed straight into the upload instead, if the
+// uploader can take a stream. It reports whether the data was uploaded, in which case the message is a link to it.
+// The returned remove function, if non-nil, deletes the temporary file.
+func (msg *Message) spoolReader(ctx context.Context) (uploaded bool, remove func(), err error) {
+	tmpdir, err := os.MkdirTemp("", "tell")
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	remove = func() {
+		os.RemoveAll(tmpdir)
+	}
+
+	path := filepath.Join(tmpdir, filepath.Base(msg.readerName))
+	spool, err := os.Create(path)
+	if err != nil {
+		return false, remove, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer spool.Close()
+
+	limit := msg.limits.fileLimit()
+	n, err := io.CopyN(spool, msg.reader, limit+1)
+	if err != nil && err != io.EOF {
+		return false, remove, fmt.Errorf("failed to read %s: %w", msg.readerName, err)
+	}
+	msg.filePath = path
+
+	if n <= limit {
+		return false, remove, spool.Close()
+	}
+
+	if msg.noUpload && !msg.split {
+		return false, remove, fmt.Errorf("%s is too big to send via Telegram and uploads are disabled", msg.readerName)
+	}
+	msg.typ = FileUploadMessage
+
+	up, ok := msg.uploaderOrDefault().(streamUploader)
+	if !ok || msg.encryptKey != nil || msg.split {
+		// The whole file is needed before it can be encrypted, split or uploaded.
+		if _, err := io.Copy(spool, msg.reader); err != nil {
+			return false, remove, fmt.Errorf("failed to read %s: %w", msg.readerName, err)
+		}
+		return false, remove, spool.Close()
+	}
+
+	first := true
+	var url string
+	err = msg.retry.do(ctx, func(ctx context.Context) error {
+		src, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer src.Close()
+
+		var r io.Reader = src
+		if first {
+			// What was read so far comes from the file, the rest is copied into it as it's uploaded.
+			first = false
+			r = io.MultiReader(io.LimitReader(src, n), io.TeeReader(msg.reader, spool))
+		} else {
+			// The previous attempt may have stopped halfway, the file needs the rest before it can be uploaded again.
+			if _, err := io.Copy(spool, msg.reader); err != nil {
+				return fmt.Errorf("failed to read %s: %w", msg.readerName, err)
+			}
+		}
+
+		url, err = up.uploadStream(ctx, filepath.Base(msg.readerName), r)
+		return err
+	})
+	if err != nil {
+		return true, remove, fmt.Errorf("failed to upload file: %w", err)
+	}
+	msg.linkUpload(url)
+	return true, remove, nil
+}
@@ -0,0 +1,76 @@
+package tell
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendReader(t *testing.T) {
+	client, api := newTestClient(t)
+
+	dump := "CREATE TABLE users (id int);\n"
+	msg := NewMessage("Backup").WithReader("dump.sql", strings.NewReader(dump))
+	if _, err := client.Send(context.Background(), msg, "alice", "bob"); err != nil {
+		t.Fatalf("failed to send reader: %s", err)
+	}
+
+	docs := api.Calls("sendDocument")
+	if len(docs) != 2 {
+		t.Fatalf("expected the document to be sent to both recipients, got %v", docs)
+	}
+	for _, doc := range docs {
+		if f := doc.Files["document"]; f.Name != "dump.sql" || string(f.Data) != dump {
+			t.Errorf("wrong document sent, got %s: %q", f.Name, f.Data)
+		}
+	}
+
+	if err := NewMessage("").WithReader("x", strings.NewReader("")).WithFile("testdata/foo").Check(SizeLimits{}); err == nil {
+		t.Errorf("a reader was accepted together with a file")
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	fastRetries(t)
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			http.Error(w, "try again", http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "https://files.example.com/abc/dump.sql\n")
+	}))
+	defer server.Close()
+
+	client, api := newTestClient(t)
+	client.cfg.FileSizeLimit = 10
+	client.cfg.Upload = UploadConfig{URL: server.URL}
+
+	dump := strings.Repeat("INSERT INTO users VALUES (1);\n", 100)
+	results, err := client.Send(context.Background(), NewMessage("").WithReader("dump.sql", strings.NewReader(dump)), "alice")
+	if err != nil {
+		t.Fatalf("failed to send reader: %s", err)
+	}
+	if r := results[0]; r.Type != FileUploadMessage || len(r.UploadURLs) != 1 || r.UploadURLs[0] != "https://files.example.com/abc/dump.sql" {
+		t.Errorf("expected the upload to be reported, got %s with URLs %v", r.Type, r.UploadURLs)
+	}
+
+	// The retry uploads the whole file again, even though the failed attempt read it already.
+	if len(bodies) != 2 || bodies[1] != dump {
+		t.Errorf("expected the dump to be uploaded again after the first attempt failed, got %d uploads", len(bodies))
+	}
+	if calls := api.Calls("sendMessage"); len(calls) != 1 || calls[0].Params["text"] != "https://files.example.com/abc/dump.sql" {
+		t.Errorf("expected a link to the upload to be sent, got %v", calls)
+	}
+
+	_, err = client.Send(context.Background(), NewMessage("").WithReader("dump.sql", strings.NewReader(dump)).WithoutUpload(), "alice")
+	if err == nil {
+		t.Errorf("a reader too large for Telegram was sent with uploads disabled")
+	}
+}
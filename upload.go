@@ -0,0 +1,365 @@
+package tell
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const userAgent = "Tell (https://github.com/mikolysz/tell)"
+
+// uploader uploads files that are too large for Telegram to an external host.
+type uploader interface {
+	// upload uploads the file at path and returns a URL it can be downloaded from.
+	upload(ctx context.Context, path string) (string, error)
+}
+
+// streamUploader is an uploader that can also upload data as it's being produced, without it being saved to a file first.
+type streamUploader interface {
+	uploader
+	// uploadStream uploads everything read from r as a file called name, and returns a URL it can be downloaded from.
+	uploadStream(ctx context.Context, name string, r io.Reader) (string, error)
+}
+
+// UploadConfig selects and configures the host large files are uploaded to.
+type UploadConfig struct {
+	Backend   string `json:"backend,omitempty"`    // One of transfer.sh (the default), 0x0, s3, scp or rsync
+	URL       string `json:"url,omitempty"`        // The service's base URL for transfer.sh and 0x0, the endpoint for s3
+	PublicURL string `json:"public_url,omitempty"` // Where uploaded files can be downloaded from, for scp, rsync and public s3 buckets
+	Target    string `json:"target,omitempty"`     // The scp or rsync destination, like user@host:/var/www/files/
+	Bucket    string `json:"bucket,omitempty"`
+	Region    string `json:"region,omitempty"`
+	AccessKey string `json:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+}
+
+// newUploader returns the uploader for the configured backend.
+func newUploader(cfg UploadConfig) (uploader, error) {
+	switch cfg.Backend {
+	case "", "transfer.sh":
+		return &putUploader{baseURL: withDefault(cfg.URL, "https://transfer.sh/")}, nil
+	case "0x0":
+		return &formUploader{url: withDefault(cfg.URL, "https://0x0.st/")}, nil
+	case "s3":
+		if cfg.URL == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+			return nil, fmt.Errorf("the s3 upload backend needs url, bucket, access_key and secret_key to be configured")
+		}
+		return &s3Uploader{
+			endpoint:  cfg.URL,
+			bucket:    cfg.Bucket,
+			region:    withDefault(cfg.Region, "us-east-1"),
+			accessKey: cfg.AccessKey,
+			secretKey: cfg.SecretKey,
+			publicURL: cfg.PublicURL,
+		}, nil
+	case "scp", "rsync":
+		if cfg.Target == "" || cfg.PublicURL == "" {
+			return nil, fmt.Errorf("the %s upload backend needs target and public_url to be configured", cfg.Backend)
+		}
+		return &copyUploader{command: cfg.Backend, target: cfg.Target, publicURL: cfg.PublicURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown upload backend %q", cfg.Backend)
+	}
+}
+
+func withDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// putUploader uploads files with a PUT request to a transfer.sh-compatible service.
+type putUploader struct {
+	baseURL string
+}
+
+func (u *putUploader) upload(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	return u.uploadStream(ctx, filepath.Base(path), f)
+}
+
+func (u *putUploader) uploadStream(ctx context.Context, name string, r io.Reader) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", strings.TrimSuffix(u.baseURL, "/")+"/"+url.PathEscape(name), r)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	return doUpload(req)
+}
+
+// formUploader uploads files as a multipart form, like 0x0.st expects.
+type formUploader struct {
+	url string
+}
+
+func (u *formUploader) upload(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	return u.uploadStream(ctx, filepath.Base(path), f)
+}
+
+func (u *formUploader) uploadStream(ctx context.Context, name string, r io.Reader) (string, error) {
+	// Stream the form instead of building it in memory, the files we upload are large by definition.
+	body, bodyWriter := io.Pipe()
+	form := multipart.NewWriter(bodyWriter)
+	go func() {
+		part, err := form.CreateFormFile("file", name)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		bodyWriter.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u.url, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return doUpload(req)
+}
+
+// doUpload sends an upload request to a service that responds with the file's URL.
+func doUpload(req *http.Request) (string, error) {
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s response: %w", req.URL.Host, err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to upload file: %w", &statusError{host: req.URL.Host, status: resp.Status, code: resp.StatusCode})
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// s3Uploader uploads files to an S3-compatible bucket using presigned URLs.
+type s3Uploader struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	publicURL string // If empty, a presigned download URL is returned instead
+}
+
+// The longest time S3 allows a presigned URL to be valid for.
+const s3MaxExpiry = 7 * 24 * time.Hour
+
+func (u *s3Uploader) upload(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	prefix, err := randomPrefix()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate object key: %w", err)
+	}
+	key := prefix + "/" + filepath.Base(path)
+
+	// Path-style URLs work with every S3-compatible service, not just AWS.
+	objectPath := "/" + awsEscape(u.bucket, false) + "/" + awsEscape(key, false)
+
+	putURL, err := u.presign("PUT", objectPath, time.Now(), 15*time.Minute)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", putURL, f)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = stat.Size()
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upload file: %w", &statusError{host: req.URL.Host, status: resp.Status, code: resp.StatusCode})
+	}
+
+	if u.publicURL != "" {
+		return strings.TrimSuffix(u.publicURL, "/") + "/" + awsEscape(key, false), nil
+	}
+	return u.presign("GET", objectPath, time.Now(), s3MaxExpiry)
+}
+
+// presign returns a URL that allows anyone to perform the given request on an object until it expires.
+// objectPath is the escaped path of the object, relative to the endpoint.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func (u *s3Uploader) presign(method, objectPath string, now time.Time, expires time.Duration) (string, error) {
+	endpoint, err := url.Parse(u.endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+
+	now = now.UTC()
+	date := now.Format("20060102")
+	scope := date + "/" + u.region + "/s3/aws4_request"
+
+	path := strings.TrimSuffix(endpoint.Path, "/") + objectPath
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    u.accessKey + "/" + scope,
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       fmt.Sprint(int(expires.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalQuery []string
+	for _, name := range names {
+		canonicalQuery = append(canonicalQuery, awsEscape(name, true)+"="+awsEscape(query[name], true))
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		strings.Join(canonicalQuery, "&"),
+		"host:" + endpoint.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		query["X-Amz-Date"],
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+u.secretKey), date)
+	signingKey = hmacSHA256(signingKey, u.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", endpoint.Scheme, endpoint.Host, path, strings.Join(canonicalQuery, "&"), signature), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape percent-encodes s the way AWS signatures expect.
+// Slashes are only encoded if encodeSlash is true, as they separate the elements of object keys.
+func awsEscape(s string, encodeSlash bool) string {
+	var b bytes.Buffer
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// copyUploader copies files to a server the user controls with scp or rsync.
+type copyUploader struct {
+	command   string // scp or rsync, both take the source and destination as their last two arguments
+	target    string
+	publicURL string
+}
+
+func (u *copyUploader) upload(ctx context.Context, path string) (string, error) {
+	// An absolute path can't be mistaken for a remote one, like a name with a colon in it would be.
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	prefix, err := randomPrefix()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate file name: %w", err)
+	}
+	// The name is used in a remote path, which the remote shell may split, so it's kept to safe characters.
+	name := prefix + "-" + unsafeNameChars.ReplaceAllString(filepath.Base(path), "_")
+
+	dest := u.target
+	if !strings.HasSuffix(dest, "/") && !strings.HasSuffix(dest, ":") {
+		dest += "/"
+	}
+	cmd := exec.CommandContext(ctx, u.command, "--", path, dest+name)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("failed to copy file with %s: %w: %s", u.command, err, msg)
+		}
+		return "", fmt.Errorf("failed to copy file with %s: %w", u.command, err)
+	}
+
+	return strings.TrimSuffix(u.publicURL, "/") + "/" + url.PathEscape(name), nil
+}
+
+// unsafeNameChars matches the characters of a file name that are replaced before it's copied to a server.
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// randomPrefix returns a random string to put before uploaded files' names,
+// which keeps files with the same name from replacing each other.
+func randomPrefix() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}